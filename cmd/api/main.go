@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // blackout windows and schedules name time zones; the runtime image has no zoneinfo
 
 	"ip-scanner/internal/database"
 	"ip-scanner/internal/handlers"
@@ -28,7 +29,8 @@ func main() {
 	// Initialize router
 	router := mux.NewRouter()
 
-	// Start the scheduler for periodic scans (every 15 minutes)
+	// Start the scheduler for periodic scans; targets without their own
+	// schedule are scanned every 15 minutes
 	scanScheduler := scheduler.NewScheduler(db, 15*time.Minute)
 	scanScheduler.Start()
 
@@ -36,12 +38,22 @@ func main() {
 	awsScheduler := scheduler.NewAWSScheduler(db, 1*time.Hour)
 	awsScheduler.Start()
 
+	// Start the data retention scheduler (every 24 hours)
+	retentionScheduler := scheduler.NewRetentionScheduler(db, 24*time.Hour)
+	retentionScheduler.Start()
+
 	// Initialize handlers
 	targetHandler := handlers.NewTargetHandler(db)
 	resultsHandler := handlers.NewResultsHandler(db)
 	awsHandler := handlers.NewAWSHandler(db)
 	notificationHandler := handlers.NewNotificationHandler(db)
 	scanHandler := handlers.NewScanHandler(scanScheduler)
+	adhocHandler := handlers.NewAdhocHandler(db, scanScheduler)
+	certificateHandler := handlers.NewCertificateHandler(db)
+	profileHandler := handlers.NewProfileHandler(db)
+	hostHandler := handlers.NewHostHandler(db)
+	blackoutHandler := handlers.NewBlackoutHandler(db)
+	retentionHandler := handlers.NewRetentionHandler(db, retentionScheduler)
 
 	// Health check endpoint
 	router.HandleFunc("/health", handlers.HealthCheck(db)).Methods("GET")
@@ -62,13 +74,42 @@ func main() {
 	api.HandleFunc("/targets", targetHandler.CreateTarget).Methods("POST")
 	api.HandleFunc("/targets/{id}", targetHandler.DeleteTarget).Methods("DELETE")
 	api.HandleFunc("/targets/{id}/toggle", targetHandler.ToggleTarget).Methods("PUT")
+	api.HandleFunc("/targets/{id}/udp", targetHandler.ToggleUDP).Methods("PUT")
+	api.HandleFunc("/targets/{id}/profile", targetHandler.AssignProfile).Methods("PUT")
+	api.HandleFunc("/targets/{id}/discovery", targetHandler.SetDiscovery).Methods("PUT")
+	api.HandleFunc("/targets/{id}/engine", targetHandler.SetEngine).Methods("PUT")
+	api.HandleFunc("/targets/{id}/schedule", targetHandler.SetSchedule).Methods("PUT")
+	api.HandleFunc("/targets/{id}/resolutions", targetHandler.GetResolutions).Methods("GET")
+
+	// Port profile endpoints
+	api.HandleFunc("/profiles", profileHandler.ListProfiles).Methods("GET")
+	api.HandleFunc("/profiles", profileHandler.CreateProfile).Methods("POST")
+	api.HandleFunc("/profiles/{id}", profileHandler.UpdateProfile).Methods("PUT")
+	api.HandleFunc("/profiles/{id}", profileHandler.DeleteProfile).Methods("DELETE")
+
+	// Blackout window endpoints
+	api.HandleFunc("/blackouts", blackoutHandler.ListBlackouts).Methods("GET")
+	api.HandleFunc("/blackouts", blackoutHandler.CreateBlackout).Methods("POST")
+	api.HandleFunc("/blackouts/{id}/toggle", blackoutHandler.ToggleBlackout).Methods("PUT")
+	api.HandleFunc("/blackouts/{id}", blackoutHandler.DeleteBlackout).Methods("DELETE")
 
 	// Scan results endpoints
 	api.HandleFunc("/results/latest", resultsHandler.GetLatestResults).Methods("GET")
+	api.HandleFunc("/results/at", resultsHandler.GetResultsAt).Methods("GET")
 	api.HandleFunc("/results/open", resultsHandler.GetOpenPorts).Methods("GET")
 	api.HandleFunc("/results/ip", resultsHandler.GetResultsByIP).Methods("GET")
 	api.HandleFunc("/results/sessions", resultsHandler.GetScanSessions).Methods("GET")
+	api.HandleFunc("/results/sessions/{id}", resultsHandler.GetSession).Methods("GET")
+	api.HandleFunc("/results/sessions/{id}/hosts", resultsHandler.GetSessionHosts).Methods("GET")
 	api.HandleFunc("/results/changes", resultsHandler.GetChangeHistory).Methods("GET")
+	api.HandleFunc("/results/diff", resultsHandler.GetDiff).Methods("GET")
+	api.HandleFunc("/results/diff/last-week", resultsHandler.GetDiffLastWeek).Methods("GET")
+
+	// Host name endpoints
+	api.HandleFunc("/hosts", hostHandler.GetHosts).Methods("GET")
+
+	// TLS certificate endpoints
+	api.HandleFunc("/certificates", certificateHandler.GetCertificates).Methods("GET")
 
 	// AWS integration endpoints
 	api.HandleFunc("/aws/credentials", awsHandler.GetCredentials).Methods("GET")
@@ -87,7 +128,16 @@ func main() {
 
 	// Scan endpoints
 	api.HandleFunc("/scan/status", scanHandler.GetStatus).Methods("GET")
+	api.HandleFunc("/scan/status/stream", scanHandler.StreamStatus).Methods("GET")
 	api.HandleFunc("/scan/trigger", scanHandler.TriggerScan).Methods("POST")
+	api.HandleFunc("/scan/cancel", scanHandler.CancelScan).Methods("POST")
+	api.HandleFunc("/scan/sessions/{id}/cancel", scanHandler.CancelSession).Methods("POST")
+	api.HandleFunc("/scan/engines", scanHandler.GetEngines).Methods("GET")
+	api.HandleFunc("/scan/adhoc", adhocHandler.StartAdhocScan).Methods("POST")
+	api.HandleFunc("/scan/adhoc/{id}", adhocHandler.GetAdhocScan).Methods("GET")
+
+	// Admin endpoints
+	api.HandleFunc("/admin/retention", retentionHandler.GetRetention).Methods("GET")
 
 	// CORS middleware for future React frontend
 	router.Use(corsMiddleware)
@@ -113,12 +163,14 @@ func main() {
 		log.Println("Shutting down server...")
 		scanScheduler.Stop()
 		awsScheduler.Stop()
+		retentionScheduler.Stop()
 		server.Close()
 	}()
 
 	log.Printf("Server starting on port %s", port)
-	log.Printf("Scheduler running - scans every 15 minutes")
+	log.Printf("Scheduler running - targets without a schedule scan every 15 minutes")
 	log.Printf("AWS sync running - syncs every 1 hour")
+	log.Printf("Data retention running - prunes every 24 hours")
 	log.Fatal(server.ListenAndServe())
 }
 
@@ -44,7 +44,8 @@ func NewEC2ServiceWithCredentials(ctx context.Context, accessKeyID, secretAccess
 	}, nil
 }
 
-// GetPublicIPs fetches all public IP addresses from EC2 instances in the current region
+// GetPublicIPs fetches all public IP addresses from EC2 instances in the current region,
+// including the IPv6 addresses assigned to their network interfaces
 func (s *EC2Service) GetPublicIPs(ctx context.Context) ([]string, error) {
 	input := &ec2.DescribeInstancesInput{}
 
@@ -54,12 +55,25 @@ func (s *EC2Service) GetPublicIPs(ctx context.Context) ([]string, error) {
 	}
 
 	var publicIPs []string
+	seen := make(map[string]bool)
+	addIP := func(ip *string) {
+		if ip != nil && *ip != "" && !seen[*ip] {
+			seen[*ip] = true
+			publicIPs = append(publicIPs, *ip)
+		}
+	}
+
 	for _, reservation := range result.Reservations {
 		for _, instance := range reservation.Instances {
 			// Only include running instances with public IPs
 			if instance.State != nil && instance.State.Name == "running" {
-				if instance.PublicIpAddress != nil && *instance.PublicIpAddress != "" {
-					publicIPs = append(publicIPs, *instance.PublicIpAddress)
+				addIP(instance.PublicIpAddress)
+				addIP(instance.Ipv6Address)
+
+				for _, eni := range instance.NetworkInterfaces {
+					for _, ipv6 := range eni.Ipv6Addresses {
+						addIP(ipv6.Ipv6Address)
+					}
 				}
 			}
 		}
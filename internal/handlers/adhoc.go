@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ip-scanner/internal/middleware"
+	"ip-scanner/internal/models"
+	"ip-scanner/internal/scanner"
+	"ip-scanner/internal/scheduler"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// adhocSyncProbes is the most port probes an ad-hoc scan may need to be
+// answered in the request; larger scans run in the background
+const adhocSyncProbes = 256
+
+// adhocSyncTimeout bounds a synchronous ad-hoc scan so the response is
+// written before the server's write timeout
+const adhocSyncTimeout = 12 * time.Second
+
+type AdhocHandler struct {
+	db        *sql.DB
+	scheduler *scheduler.Scheduler
+}
+
+func NewAdhocHandler(db *sql.DB, s *scheduler.Scheduler) *AdhocHandler {
+	return &AdhocHandler{
+		db:        db,
+		scheduler: s,
+	}
+}
+
+// StartAdhocScan handles POST /api/v1/scan/adhoc. Small scans are run while
+// the client waits and answered with 200 and the results; larger ones are
+// queued and answered with 202 and a job ID to poll. Either way only the
+// ports that are open or may be are returned.
+func (h *AdhocHandler) StartAdhocScan(w http.ResponseWriter, r *http.Request) {
+	var req models.AdhocScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Target == "" {
+		http.Error(w, "Target is required", http.StatusBadRequest)
+		return
+	}
+
+	target, err := scanner.ParseTarget(req.Target)
+	if err != nil {
+		http.Error(w, "Invalid target: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := target.CheckSize(false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ports := scanner.CommonPorts
+	if req.Ports != "" {
+		if ports, err = scanner.ParsePortSpec(req.Ports); err != nil {
+			http.Error(w, "Invalid ports: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	udpPorts := []int{}
+	if req.UDPPorts != "" {
+		if udpPorts, err = scanner.ParsePortSpec(req.UDPPorts); err != nil {
+			http.Error(w, "Invalid UDP ports: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	engine := req.ScanEngine
+	if engine == "" {
+		engine = h.scheduler.DefaultEngine()
+	} else if !scanner.IsTCPEngine(engine) {
+		http.Error(w, "Invalid scan engine: "+engine, http.StatusBadRequest)
+		return
+	}
+
+	scan := scheduler.AdhocScan{
+		Target:   target,
+		Ports:    ports,
+		UDPPorts: udpPorts,
+		Engine:   engine,
+	}
+
+	// Host names count as one address each until they are resolved
+	addresses := target.Count() + uint64(len(target.Hostnames()))
+	probes := addresses * uint64(len(ports)+len(udpPorts))
+	if probes > scheduler.MaxAdhocProbes {
+		http.Error(w, "Ad-hoc scan would send "+strconv.FormatUint(probes, 10)+" probes, at most "+
+			strconv.Itoa(scheduler.MaxAdhocProbes)+" are allowed; scan fewer ports or addresses", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if probes > adhocSyncProbes {
+		jobID, err := h.scheduler.StartAdhoc(scan, req.Target, middleware.UsernameFromContext(r.Context()))
+		if errors.Is(err, scheduler.ErrTooManyAdhocScans) {
+			http.Error(w, "Too many ad-hoc scans running, try again later", http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to start ad-hoc scan: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.AdhocScan{
+			ID:       jobID,
+			Target:   req.Target,
+			Ports:    ports,
+			UDPPorts: udpPorts,
+			Engine:   engine,
+			Status:   "running",
+			Results:  []models.AdhocResult{},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), adhocSyncTimeout)
+	defer cancel()
+
+	createdAt := time.Now()
+	results, err := h.scheduler.RunAdhoc(ctx, scan)
+	if errors.Is(err, scheduler.ErrTooManyAdhocScans) {
+		http.Error(w, "Too many ad-hoc scans running, try again later", http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, scheduler.ErrAdhocUnresolved) || errors.Is(err, scheduler.ErrAdhocTooLarge) {
+		http.Error(w, "Invalid target: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ad-hoc scan did not finish in time, scan fewer ports or addresses", http.StatusGatewayTimeout)
+		return
+	}
+	completedAt := time.Now()
+
+	json.NewEncoder(w).Encode(models.AdhocScan{
+		Target:      req.Target,
+		Ports:       ports,
+		UDPPorts:    udpPorts,
+		Engine:      engine,
+		Status:      "completed",
+		Results:     results,
+		CreatedAt:   createdAt,
+		CompletedAt: &completedAt,
+	})
+}
+
+// GetAdhocScan handles GET /api/v1/scan/adhoc/{id}
+func (h *AdhocHandler) GetAdhocScan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid scan ID", http.StatusBadRequest)
+		return
+	}
+
+	var job models.AdhocScan
+	var ports, udpPorts pq.Int64Array
+	var results []byte
+
+	err = h.db.QueryRow(`
+		SELECT id, target, ports, udp_ports, engine, status, COALESCE(requested_by, ''),
+		       COALESCE(error, ''), COALESCE(results, '[]'::jsonb), created_at, completed_at
+		FROM adhoc_scans
+		WHERE id = $1
+	`, id).Scan(
+		&job.ID, &job.Target, &ports, &udpPorts, &job.Engine, &job.Status, &job.RequestedBy,
+		&job.Error, &results, &job.CreatedAt, &job.CompletedAt,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Ad-hoc scan not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch ad-hoc scan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	job.Ports = intsFromArray(ports)
+	job.UDPPorts = intsFromArray(udpPorts)
+	if err := json.Unmarshal(results, &job.Results); err != nil {
+		http.Error(w, "Failed to parse ad-hoc scan results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func intsFromArray(array pq.Int64Array) []int {
+	ints := make([]int, len(array))
+	for i, v := range array {
+		ints[i] = int(v)
+	}
+	return ints
+}
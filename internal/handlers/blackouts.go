@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ip-scanner/internal/models"
+	"ip-scanner/internal/scheduler"
+)
+
+type BlackoutHandler struct {
+	db *sql.DB
+}
+
+func NewBlackoutHandler(db *sql.DB) *BlackoutHandler {
+	return &BlackoutHandler{db: db}
+}
+
+const blackoutColumns = `id, name, target_id, COALESCE(recurrence, ''), COALESCE(duration_minutes, 0),
+	timezone, starts_at, ends_at, action, enabled, created_at`
+
+// scanBlackout reads a blackout_windows row selected with blackoutColumns and
+// works out whether the window is in force now
+func scanBlackout(row rowScanner) (models.BlackoutWindow, error) {
+	var window models.BlackoutWindow
+	var targetID sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(
+		&window.ID, &window.Name, &targetID, &window.Recurrence, &window.DurationMinutes,
+		&window.Timezone, &startsAt, &endsAt, &window.Action, &window.Enabled, &window.CreatedAt,
+	)
+	if err != nil {
+		return window, err
+	}
+
+	if targetID.Valid {
+		id := int(targetID.Int64)
+		window.TargetID = &id
+	}
+	if startsAt.Valid {
+		window.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		window.EndsAt = &endsAt.Time
+	}
+
+	if window.Enabled {
+		if until, ok := scheduler.BlackoutEnd(window, time.Now()); ok {
+			window.Active = true
+			window.ActiveUntil = &until
+		}
+	}
+
+	return window, nil
+}
+
+// ListBlackouts handles GET /api/v1/blackouts
+// Optionally filtered to the windows affecting one target with ?target_id=
+func (h *BlackoutHandler) ListBlackouts(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT ` + blackoutColumns + `
+		FROM blackout_windows
+	`
+	args := []interface{}{}
+
+	if targetIDParam := r.URL.Query().Get("target_id"); targetIDParam != "" {
+		targetID, err := strconv.Atoi(targetIDParam)
+		if err != nil {
+			http.Error(w, "Invalid target ID", http.StatusBadRequest)
+			return
+		}
+		query += ` WHERE target_id IS NULL OR target_id = $1`
+		args = append(args, targetID)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, "Failed to fetch blackout windows: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	windows := []models.BlackoutWindow{}
+	for rows.Next() {
+		window, err := scanBlackout(rows)
+		if err != nil {
+			http.Error(w, "Failed to parse blackout windows: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		windows = append(windows, window)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(windows)
+}
+
+// CreateBlackout handles POST /api/v1/blackouts
+func (h *BlackoutHandler) CreateBlackout(w http.ResponseWriter, r *http.Request) {
+	var req models.BlackoutWindowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Window name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.Action == "" {
+		req.Action = scheduler.BlackoutDefer
+	}
+	req.Recurrence = strings.TrimSpace(req.Recurrence)
+
+	// One-off windows are stored in UTC
+	var startsAt, endsAt *time.Time
+	if req.StartsAt != nil {
+		t := req.StartsAt.UTC()
+		startsAt = &t
+	}
+	if req.EndsAt != nil {
+		t := req.EndsAt.UTC()
+		endsAt = &t
+	}
+
+	window := models.BlackoutWindow{
+		Name:            req.Name,
+		TargetID:        req.TargetID,
+		Recurrence:      req.Recurrence,
+		DurationMinutes: req.DurationMinutes,
+		Timezone:        req.Timezone,
+		StartsAt:        startsAt,
+		EndsAt:          endsAt,
+		Action:          req.Action,
+	}
+	if err := scheduler.ValidateBlackout(window); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	window, err := scanBlackout(h.db.QueryRow(`
+		INSERT INTO blackout_windows (name, target_id, recurrence, duration_minutes, timezone, starts_at, ends_at, action)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), $5, $6, $7, $8)
+		RETURNING `+blackoutColumns,
+		window.Name, window.TargetID, window.Recurrence, window.DurationMinutes,
+		window.Timezone, startsAt, endsAt, window.Action,
+	))
+	if err != nil {
+		if strings.Contains(err.Error(), "blackout_windows_target_id_fkey") {
+			http.Error(w, "Target not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to create blackout window: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(window)
+}
+
+// ToggleBlackout handles PUT /api/v1/blackouts/{id}/toggle
+func (h *BlackoutHandler) ToggleBlackout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid blackout window ID", http.StatusBadRequest)
+		return
+	}
+
+	window, err := scanBlackout(h.db.QueryRow(`
+		UPDATE blackout_windows
+		SET enabled = NOT enabled
+		WHERE id = $1
+		RETURNING `+blackoutColumns,
+		id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Blackout window not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to toggle blackout window: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(window)
+}
+
+// DeleteBlackout handles DELETE /api/v1/blackouts/{id}
+func (h *BlackoutHandler) DeleteBlackout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid blackout window ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM blackout_windows WHERE id = $1", id)
+	if err != nil {
+		http.Error(w, "Failed to delete blackout window: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Blackout window not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"ip-scanner/internal/models"
+)
+
+type CertificateHandler struct {
+	db *sql.DB
+}
+
+func NewCertificateHandler(db *sql.DB) *CertificateHandler {
+	return &CertificateHandler{db: db}
+}
+
+// GetCertificates handles GET /api/v1/certificates
+// Returns the certificate last seen on each TLS port, optionally filtered by
+// IP (?ip=) or to those expiring within a number of days (?expiring_within=)
+func (h *CertificateHandler) GetCertificates(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT id, target_id, ip_address, port, subject, issuer, sans, not_before, not_after,
+		       COALESCE(key_type, ''), COALESCE(key_size, 0), self_signed,
+		       COALESCE(tls_version, ''), COALESCE(cipher_suite, ''),
+		       fingerprint_sha256, COALESCE(chain, '[]'::jsonb), first_seen_at, last_seen_at
+		FROM tls_certificates
+		WHERE 1 = 1
+	`
+	args := []interface{}{}
+
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		args = append(args, ip)
+		query += " AND ip_address = $" + strconv.Itoa(len(args))
+	}
+
+	if within := r.URL.Query().Get("expiring_within"); within != "" {
+		days, err := strconv.Atoi(within)
+		if err != nil || days < 0 {
+			http.Error(w, "expiring_within must be a non-negative number of days", http.StatusBadRequest)
+			return
+		}
+		args = append(args, days)
+		query += " AND not_after < NOW() + ($" + strconv.Itoa(len(args)) + " * INTERVAL '1 day')"
+	}
+
+	query += " ORDER BY not_after ASC"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, "Failed to fetch certificates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	certificates := []models.TLSCertificate{}
+	for rows.Next() {
+		var cert models.TLSCertificate
+		var targetID sql.NullInt64
+		var chain []byte
+
+		err := rows.Scan(
+			&cert.ID, &targetID, &cert.IPAddress, &cert.Port, &cert.Subject, &cert.Issuer,
+			pq.Array(&cert.SANs), &cert.NotBefore, &cert.NotAfter,
+			&cert.KeyType, &cert.KeySize, &cert.SelfSigned,
+			&cert.TLSVersion, &cert.CipherSuite,
+			&cert.FingerprintSHA256, &chain, &cert.FirstSeenAt, &cert.LastSeenAt,
+		)
+		if err != nil {
+			http.Error(w, "Failed to parse certificates: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if targetID.Valid {
+			targetIDInt := int(targetID.Int64)
+			cert.TargetID = &targetIDInt
+		}
+		if cert.SANs == nil {
+			cert.SANs = []string{}
+		}
+		cert.Chain = chain
+		cert.DaysRemaining = int(time.Until(cert.NotAfter).Hours() / 24)
+
+		certificates = append(certificates, cert)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certificates)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"ip-scanner/internal/models"
+)
+
+// GetDiff handles GET /api/v1/results/diff?from={session}&to={session}
+// Compares the port states as of two sessions. The state as of a session is
+// the latest event of every IP/port by the time it finished, since a
+// session only scans the targets that were due. The comparison covers the
+// target given by ?target_id=, or else the target the to session scanned.
+// to defaults to the latest completed session of that target.
+func (h *ResultsHandler) GetDiff(w http.ResponseWriter, r *http.Request) {
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "A from session ID is required", http.StatusBadRequest)
+		return
+	}
+
+	targetID, ok := diffTarget(w, r)
+	if !ok {
+		return
+	}
+
+	toID, ok := h.diffToSession(w, r, targetID)
+	if !ok {
+		return
+	}
+
+	h.writeDiff(w, fromID, toID, targetID)
+}
+
+// GetDiffLastWeek handles GET /api/v1/results/diff/last-week
+// Compares the latest completed session, or ?to=, with the last one of the
+// same target that completed at least a week before it. ?target_id= picks
+// the target as for GetDiff.
+func (h *ResultsHandler) GetDiffLastWeek(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := diffTarget(w, r)
+	if !ok {
+		return
+	}
+
+	toID, ok := h.diffToSession(w, r, targetID)
+	if !ok {
+		return
+	}
+
+	// Sessions from before targets were scanned separately covered them all
+	var fromID int
+	err := h.db.QueryRow(`
+		WITH to_session AS (SELECT started_at, target_id FROM scan_sessions WHERE id = $1)
+		SELECT ss.id FROM scan_sessions ss, to_session ts
+		WHERE ss.status = 'completed'
+		  AND ss.started_at <= ts.started_at - INTERVAL '7 days'
+		  AND (ss.target_id IS NULL OR ss.target_id = COALESCE(NULLIF($2, 0), ts.target_id))
+		ORDER BY ss.started_at DESC
+		LIMIT 1
+	`, toID, targetID).Scan(&fromID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No completed session from a week earlier", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to find last week's session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeDiff(w, fromID, toID, targetID)
+}
+
+// diffTarget returns the target given by ?target_id=, or 0 if there is
+// none. It writes the error response itself and returns false on failure.
+func diffTarget(w http.ResponseWriter, r *http.Request) (int, bool) {
+	targetIDParam := r.URL.Query().Get("target_id")
+	if targetIDParam == "" {
+		return 0, true
+	}
+	targetID, err := strconv.Atoi(targetIDParam)
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return targetID, true
+}
+
+// diffToSession returns the session given by ?to=, or the latest completed
+// one of targetID, or of any target when it is 0. It writes the error
+// response itself and returns false on failure.
+func (h *ResultsHandler) diffToSession(w http.ResponseWriter, r *http.Request, targetID int) (int, bool) {
+	if toParam := r.URL.Query().Get("to"); toParam != "" {
+		toID, err := strconv.Atoi(toParam)
+		if err != nil {
+			http.Error(w, "Invalid to session ID", http.StatusBadRequest)
+			return 0, false
+		}
+		return toID, true
+	}
+
+	var toID int
+	err := h.db.QueryRow(`
+		SELECT id FROM scan_sessions
+		WHERE status = 'completed' AND ($1 = 0 OR target_id = $1)
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, targetID).Scan(&toID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No completed scan sessions", http.StatusNotFound)
+		return 0, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to find latest session: "+err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+	return toID, true
+}
+
+// writeDiff compares the states as of sessions fromID and toID of the ports
+// of targetID, or of the target toID scanned when it is 0. A host is taken
+// to be present while it has at least one open port.
+func (h *ResultsHandler) writeDiff(w http.ResponseWriter, fromID, toID, targetID int) {
+	diff := models.SessionDiff{
+		PortsOpened:      []models.DiffPort{},
+		PortsClosed:      []models.DiffPort{},
+		HostsAppeared:    []string{},
+		HostsDisappeared: []string{},
+	}
+
+	for _, s := range []struct {
+		id      int
+		session *models.ScanSession
+	}{{fromID, &diff.From}, {toID, &diff.To}} {
+		session, err := scanSession(h.db.QueryRow(`
+			SELECT `+sessionColumns+`
+			FROM scan_sessions
+			WHERE id = $1
+		`, s.id))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Session "+strconv.Itoa(s.id)+" not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to fetch session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		*s.session = session
+	}
+
+	// Both sessions must have scanned the target compared; sessions without
+	// a target scanned them all
+	if targetID == 0 && diff.To.TargetID != nil {
+		targetID = *diff.To.TargetID
+	}
+	for _, session := range []models.ScanSession{diff.From, diff.To} {
+		if targetID != 0 && session.TargetID != nil && *session.TargetID != targetID {
+			http.Error(w, "Session "+strconv.Itoa(session.ID)+" scanned a different target", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Every IP/port that was open on either side, with its status on both
+	rows, err := h.db.Query(`
+		WITH state_from AS (
+			SELECT DISTINCT ON (ip_address, port, protocol)
+				ip_address, port, protocol, status, target_id
+			FROM port_events
+			WHERE occurred_at <= (SELECT COALESCE(completed_at, NOW()) FROM scan_sessions WHERE id = $1)
+			  AND ($3 = 0 OR target_id = $3)
+			ORDER BY ip_address, port, protocol, occurred_at DESC, id DESC
+		),
+		state_to AS (
+			SELECT DISTINCT ON (ip_address, port, protocol)
+				ip_address, port, protocol, status, target_id
+			FROM port_events
+			WHERE occurred_at <= (SELECT COALESCE(completed_at, NOW()) FROM scan_sessions WHERE id = $2)
+			  AND ($3 = 0 OR target_id = $3)
+			ORDER BY ip_address, port, protocol, occurred_at DESC, id DESC
+		)
+		SELECT host(COALESCE(t.ip_address, f.ip_address)), COALESCE(t.port, f.port),
+		       COALESCE(t.protocol, f.protocol), COALESCE(f.status, ''), COALESCE(t.status, ''),
+		       COALESCE(t.target_id, f.target_id)
+		FROM state_from f
+		FULL OUTER JOIN state_to t
+			ON f.ip_address = t.ip_address AND f.port = t.port AND f.protocol = t.protocol
+		WHERE f.status = 'open' OR t.status = 'open'
+		ORDER BY COALESCE(t.ip_address, f.ip_address), COALESCE(t.port, f.port), COALESCE(t.protocol, f.protocol)
+	`, fromID, toID, targetID)
+	if err != nil {
+		http.Error(w, "Failed to compare sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Rows arrive grouped by IP, so a host is settled when the IP changes
+	var currentIP string
+	var openBefore, openAfter bool
+	settleHost := func() {
+		if currentIP == "" {
+			return
+		}
+		if openAfter && !openBefore {
+			diff.HostsAppeared = append(diff.HostsAppeared, currentIP)
+		}
+		if openBefore && !openAfter {
+			diff.HostsDisappeared = append(diff.HostsDisappeared, currentIP)
+		}
+	}
+
+	for rows.Next() {
+		var port models.DiffPort
+		var fromStatus string
+		err := rows.Scan(
+			&port.IPAddress, &port.Port, &port.Protocol,
+			&fromStatus, &port.Status, &port.TargetID,
+		)
+		if err != nil {
+			http.Error(w, "Failed to parse comparison: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		port.PreviousStatus = fromStatus
+
+		// A UDP probe without a reply says nothing either way
+		if fromStatus == "open|filtered" {
+			fromStatus = ""
+		}
+		toStatus := port.Status
+		if toStatus == "open|filtered" {
+			toStatus = ""
+		}
+
+		if port.IPAddress != currentIP {
+			settleHost()
+			currentIP, openBefore, openAfter = port.IPAddress, false, false
+		}
+		// A port the later session knows nothing about is taken as unchanged
+		openBefore = openBefore || fromStatus == "open"
+		openAfter = openAfter || toStatus == "open" || (toStatus == "" && fromStatus == "open")
+
+		switch {
+		case toStatus == "open" && fromStatus != "open":
+			diff.PortsOpened = append(diff.PortsOpened, port)
+		case fromStatus == "open" && toStatus != "open" && toStatus != "":
+			diff.PortsClosed = append(diff.PortsClosed, port)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Failed to compare sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	settleHost()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
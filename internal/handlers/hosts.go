@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"ip-scanner/internal/models"
+)
+
+type HostHandler struct {
+	db *sql.DB
+}
+
+func NewHostHandler(db *sql.DB) *HostHandler {
+	return &HostHandler{db: db}
+}
+
+// likeEscaper escapes the characters LIKE treats specially; backslash is
+// Postgres' default LIKE escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern returns an ILIKE pattern matching values that contain
+// search literally
+func containsPattern(search string) string {
+	return "%" + likeEscaper.Replace(search) + "%"
+}
+
+// GetHosts handles GET /api/v1/hosts
+// Returns cached host names, optionally filtered by ?search= on IP or name
+func (h *HostHandler) GetHosts(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT ip_address, COALESCE(ptr_names[1], cert_names[1], ''),
+		       ptr_names, cert_names, ptr_resolved_at, updated_at
+		FROM hosts
+	`
+	args := []interface{}{}
+
+	if search := r.URL.Query().Get("search"); search != "" {
+		args = append(args, containsPattern(search))
+		query += ` WHERE host(ip_address) ILIKE $1
+			OR array_to_string(ptr_names, ' ') ILIKE $1
+			OR array_to_string(cert_names, ' ') ILIKE $1`
+	}
+
+	query += " ORDER BY ip_address LIMIT 500"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, "Failed to fetch hosts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	hosts := []models.Host{}
+	for rows.Next() {
+		var host models.Host
+		err := rows.Scan(
+			&host.IPAddress, &host.Hostname,
+			pq.Array(&host.PTRNames), pq.Array(&host.CertNames),
+			&host.PTRResolvedAt, &host.UpdatedAt,
+		)
+		if err != nil {
+			http.Error(w, "Failed to parse hosts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hosts = append(hosts, host)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hosts)
+}
@@ -20,22 +20,34 @@ func NewNotificationHandler(db *sql.DB) *NotificationHandler {
 }
 
 // GetNotifications handles GET /api/v1/notifications
-// Returns all notifications, optionally filtered by read status
+// Returns all notifications, optionally filtered by read status and by the
+// scan session that raised them (?session_id=)
 func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
 	unreadOnly := r.URL.Query().Get("unread_only") == "true"
 
 	query := `
-		SELECT id, type, title, message, severity, ip_address, port, target_id, is_read, created_at
+		SELECT id, type, title, message, severity, ip_address, port, target_id, session_id, is_read, created_at
 		FROM notifications
+		WHERE 1 = 1
 	`
+	args := []interface{}{}
 
 	if unreadOnly {
-		query += " WHERE is_read = false"
+		query += " AND is_read = false"
+	}
+	if sessionIDParam := r.URL.Query().Get("session_id"); sessionIDParam != "" {
+		sessionID, err := strconv.Atoi(sessionIDParam)
+		if err != nil {
+			http.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+		args = append(args, sessionID)
+		query += " AND session_id = $1"
 	}
 
 	query += " ORDER BY created_at DESC LIMIT 100"
 
-	rows, err := h.db.Query(query)
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		http.Error(w, "Failed to fetch notifications: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -46,7 +58,7 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 	for rows.Next() {
 		var notif models.Notification
 		var ipAddress sql.NullString
-		var port, targetID sql.NullInt64
+		var port, targetID, sessionID sql.NullInt64
 
 		err := rows.Scan(
 			&notif.ID,
@@ -57,6 +69,7 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 			&ipAddress,
 			&port,
 			&targetID,
+			&sessionID,
 			&notif.IsRead,
 			&notif.CreatedAt,
 		)
@@ -76,6 +89,10 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 			targetIDInt := int(targetID.Int64)
 			notif.TargetID = &targetIDInt
 		}
+		if sessionID.Valid {
+			sessionIDInt := int(sessionID.Int64)
+			notif.SessionID = &sessionIDInt
+		}
 
 		notifications = append(notifications, notif)
 	}
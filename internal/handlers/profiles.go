@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"ip-scanner/internal/models"
+	"ip-scanner/internal/scanner"
+)
+
+type ProfileHandler struct {
+	db *sql.DB
+}
+
+func NewProfileHandler(db *sql.DB) *ProfileHandler {
+	return &ProfileHandler{db: db}
+}
+
+const profileColumns = `id, name, COALESCE(description, ''), ports, COALESCE(udp_ports, ''), builtin, created_at, updated_at`
+
+// scanProfile reads a port_profiles row selected with profileColumns and
+// fills in the expanded port count
+func scanProfile(row rowScanner) (models.PortProfile, error) {
+	var profile models.PortProfile
+	err := row.Scan(
+		&profile.ID, &profile.Name, &profile.Description, &profile.Ports,
+		&profile.UDPPorts, &profile.Builtin, &profile.CreatedAt, &profile.UpdatedAt,
+	)
+	if err != nil {
+		return profile, err
+	}
+
+	if ports, err := scanner.ParsePortSpec(profile.Ports); err == nil {
+		profile.PortCount = len(ports)
+	}
+
+	return profile, nil
+}
+
+// validateProfileRequest checks the name and port lists, returning a message
+// suitable for the client when they are invalid
+func validateProfileRequest(req *models.PortProfileRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Profile name is required"
+	}
+	if _, err := scanner.ParsePortSpec(req.Ports); err != nil {
+		return "Invalid ports: " + err.Error()
+	}
+	if req.UDPPorts != "" {
+		if _, err := scanner.ParsePortSpec(req.UDPPorts); err != nil {
+			return "Invalid UDP ports: " + err.Error()
+		}
+	}
+	return ""
+}
+
+// ListProfiles handles GET /api/v1/profiles
+func (h *ProfileHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT ` + profileColumns + `
+		FROM port_profiles
+		ORDER BY builtin DESC, name ASC
+	`)
+	if err != nil {
+		http.Error(w, "Failed to fetch profiles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	profiles := []models.PortProfile{}
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			http.Error(w, "Failed to parse profiles: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		profiles = append(profiles, profile)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// CreateProfile handles POST /api/v1/profiles
+func (h *ProfileHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	var req models.PortProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateProfileRequest(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	profile, err := scanProfile(h.db.QueryRow(`
+		INSERT INTO port_profiles (name, description, ports, udp_ports)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING `+profileColumns,
+		req.Name, req.Description, req.Ports, req.UDPPorts,
+	))
+
+	if err != nil {
+		if strings.Contains(err.Error(), "port_profiles_name_key") {
+			http.Error(w, "A profile with this name already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(profile)
+}
+
+// UpdateProfile handles PUT /api/v1/profiles/{id}
+// Built-in profiles are read-only
+func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid profile ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.PortProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateProfileRequest(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	profile, err := scanProfile(h.db.QueryRow(`
+		UPDATE port_profiles
+		SET name = $1, description = $2, ports = $3, udp_ports = NULLIF($4, ''), updated_at = NOW()
+		WHERE id = $5 AND builtin = false
+		RETURNING `+profileColumns,
+		req.Name, req.Description, req.Ports, req.UDPPorts, id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Profile not found or is built-in", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "port_profiles_name_key") {
+			http.Error(w, "A profile with this name already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// DeleteProfile handles DELETE /api/v1/profiles/{id}
+// Targets using the profile fall back to the common ports
+func (h *ProfileHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid profile ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM port_profiles WHERE id = $1 AND builtin = false", id)
+	if err != nil {
+		http.Error(w, "Failed to delete profile", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Profile not found or is built-in", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,9 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"ip-scanner/internal/models"
+
+	"github.com/gorilla/mux"
 )
 
 type ResultsHandler struct {
@@ -17,57 +23,61 @@ func NewResultsHandler(db *sql.DB) *ResultsHandler {
 }
 
 // GetLatestResults handles GET /api/v1/results/latest
+// Optional ?search= matches IP, host name or target description
 func (h *ResultsHandler) GetLatestResults(w http.ResponseWriter, r *http.Request) {
 	// Get the most recent scan results for each IP/port combination
-	rows, err := h.db.Query(`
-		WITH latest_scans AS (
-			SELECT DISTINCT ON (ip_address, port)
-				sr.id, sr.target_id, sr.ip_address, sr.port,
-				sr.status, sr.scanned_at, sr.response_time_ms,
-				st.description as target_description
-			FROM scan_results sr
-			JOIN scan_targets st ON sr.target_id = st.id
-			ORDER BY ip_address, port, scanned_at DESC
-		),
-		first_seen AS (
-			SELECT ip_address, port, MIN(scanned_at) as first_discovered_at
-			FROM scan_results
-			WHERE status = 'open'
-			GROUP BY ip_address, port
-		)
-		SELECT ls.id, ls.target_id, ls.ip_address, ls.port, ls.status,
-		       ls.scanned_at, ls.response_time_ms, ls.target_description,
-		       fs.first_discovered_at
-		FROM latest_scans ls
-		LEFT JOIN first_seen fs ON ls.ip_address = fs.ip_address AND ls.port = fs.port
-		ORDER BY ls.ip_address, ls.port
-	`)
+	results, err := h.queryLatestResults(false, r.URL.Query().Get("search"), nil)
 	if err != nil {
 		http.Error(w, "Failed to fetch results: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	results := []models.ScanResultWithTarget{}
-	for rows.Next() {
-		var result models.ScanResultWithTarget
-		err := rows.Scan(
-			&result.ID, &result.TargetID, &result.IPAddress, &result.Port,
-			&result.Status, &result.ScannedAt, &result.ResponseTimeMs,
-			&result.TargetDescription, &result.FirstDiscoveredAt,
-		)
-		if err != nil {
-			http.Error(w, "Failed to parse results", http.StatusInternalServerError)
-			return
-		}
-		results = append(results, result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// GetResultsAt handles GET /api/v1/results/at?time=...
+// Reconstructs the latest known state of every IP/port as of a point in
+// time, in the same shape as /results/latest. time is RFC 3339, or a date
+// and time without a zone taken as UTC. Optional ?search= as for latest.
+func (h *ResultsHandler) GetResultsAt(w http.ResponseWriter, r *http.Request) {
+	asOf, err := parseAsOf(r.URL.Query().Get("time"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.queryLatestResults(false, r.URL.Query().Get("search"), &asOf)
+	if err != nil {
+		http.Error(w, "Failed to fetch results: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
 }
 
+// asOfLayouts are the zoneless forms accepted besides RFC 3339
+var asOfLayouts = []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+func parseAsOf(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("time is required")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range asOfLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (use RFC 3339, e.g. 2026-03-01T14:00:00Z)", value)
+}
+
 // GetResultsByIP handles GET /api/v1/results/ip/{ip}
+// Returns the state changes of the IP's ports, newest first, each with when
+// that state was last seen
 func (h *ResultsHandler) GetResultsByIP(w http.ResponseWriter, r *http.Request) {
 	ip := r.URL.Query().Get("ip")
 	if ip == "" {
@@ -76,13 +86,15 @@ func (h *ResultsHandler) GetResultsByIP(w http.ResponseWriter, r *http.Request)
 	}
 
 	rows, err := h.db.Query(`
-		SELECT sr.id, sr.target_id, sr.ip_address, sr.port,
-			   sr.status, sr.scanned_at, sr.response_time_ms,
+		SELECT pe.id, pe.target_id, pe.ip_address, pe.port, pe.protocol,
+			   pe.status, pe.last_seen_at, pe.response_time_ms, COALESCE(pe.engine, ''),
+			   COALESCE(ho.ptr_names[1], ho.cert_names[1], ''),
 			   st.description as target_description
-		FROM scan_results sr
-		JOIN scan_targets st ON sr.target_id = st.id
-		WHERE sr.ip_address = $1
-		ORDER BY sr.scanned_at DESC, sr.port
+		FROM port_events pe
+		JOIN scan_targets st ON pe.target_id = st.id
+		LEFT JOIN hosts ho ON pe.ip_address = ho.ip_address
+		WHERE pe.ip_address = $1
+		ORDER BY pe.occurred_at DESC, pe.port
 		LIMIT 100
 	`, ip)
 	if err != nil {
@@ -96,7 +108,8 @@ func (h *ResultsHandler) GetResultsByIP(w http.ResponseWriter, r *http.Request)
 		var result models.ScanResultWithTarget
 		err := rows.Scan(
 			&result.ID, &result.TargetID, &result.IPAddress, &result.Port,
-			&result.Status, &result.ScannedAt, &result.ResponseTimeMs,
+			&result.Protocol, &result.Status, &result.ScannedAt, &result.ResponseTimeMs,
+			&result.Engine, &result.Hostname,
 			&result.TargetDescription,
 		)
 		if err != nil {
@@ -111,36 +124,87 @@ func (h *ResultsHandler) GetResultsByIP(w http.ResponseWriter, r *http.Request)
 }
 
 // GetOpenPorts handles GET /api/v1/results/open
+// Optional ?search= matches IP, host name or target description
 func (h *ResultsHandler) GetOpenPorts(w http.ResponseWriter, r *http.Request) {
 	// Get only open ports from the latest scan
-	rows, err := h.db.Query(`
-		WITH latest_scans AS (
-			SELECT DISTINCT ON (ip_address, port)
-				sr.id, sr.target_id, sr.ip_address, sr.port,
-				sr.status, sr.scanned_at, sr.response_time_ms,
-				st.description as target_description
-			FROM scan_results sr
-			JOIN scan_targets st ON sr.target_id = st.id
-			ORDER BY ip_address, port, scanned_at DESC
-		),
-		first_seen AS (
-			SELECT ip_address, port, MIN(scanned_at) as first_discovered_at
-			FROM scan_results
-			WHERE status = 'open'
-			GROUP BY ip_address, port
-		)
-		SELECT ls.id, ls.target_id, ls.ip_address, ls.port, ls.status,
-		       ls.scanned_at, ls.response_time_ms, ls.target_description,
-		       fs.first_discovered_at
-		FROM latest_scans ls
-		LEFT JOIN first_seen fs ON ls.ip_address = fs.ip_address AND ls.port = fs.port
-		WHERE ls.status = 'open'
-		ORDER BY ls.ip_address, ls.port
-	`)
+	results, err := h.queryLatestResults(true, r.URL.Query().Get("search"), nil)
 	if err != nil {
 		http.Error(w, "Failed to fetch results", http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// queryLatestResults returns the current state of every IP/port, enriched
+// with the host name cached for the IP and whether the port is flapping.
+// With asOf set the state is rebuilt from the latest event of each IP/port
+// at that time instead, and flapping is left unset since only the current
+// flap state is kept.
+func (h *ResultsHandler) queryLatestResults(openOnly bool, search string, asOf *time.Time) ([]models.ScanResultWithTarget, error) {
+	args := []interface{}{}
+	latest := `
+		SELECT COALESCE(event_id, 0) AS id, target_id, ip_address, port, protocol, status,
+		       last_seen_at AS scanned_at, response_time_ms,
+		       service_name, service_product, service_version, engine,
+		       first_open_at AS first_discovered_at
+		FROM port_state
+	`
+	flapJoin := "LEFT JOIN port_flaps pf ON ls.ip_address = pf.ip_address AND ls.port = pf.port AND ls.protocol = pf.protocol"
+	if asOf != nil {
+		args = append(args, *asOf)
+		// A state last seen after asOf was already in place at asOf
+		latest = `
+			SELECT DISTINCT ON (ip_address, port, protocol)
+			       id, target_id, ip_address, port, protocol, status,
+			       LEAST(last_seen_at, $1) AS scanned_at, response_time_ms,
+			       service_name, service_product, service_version, engine,
+			       (SELECT MIN(fo.occurred_at) FROM port_events fo
+			        WHERE fo.ip_address = pe.ip_address AND fo.port = pe.port AND fo.protocol = pe.protocol
+			          AND fo.status = 'open' AND fo.occurred_at <= $1) AS first_discovered_at
+			FROM port_events pe
+			WHERE occurred_at <= $1
+			ORDER BY ip_address, port, protocol, occurred_at DESC, id DESC
+		`
+		flapJoin = "LEFT JOIN port_flaps pf ON false"
+	}
+
+	query := `
+		WITH latest_scans AS (` + latest + `)
+		SELECT ls.id, ls.target_id, ls.ip_address, ls.port, ls.protocol, ls.status,
+		       ls.scanned_at, ls.response_time_ms,
+		       COALESCE(ls.service_name, ''), COALESCE(ls.service_product, ''), COALESCE(ls.service_version, ''),
+		       COALESCE(ls.engine, ''),
+		       COALESCE(ho.ptr_names[1], ho.cert_names[1], ''),
+		       st.description,
+		       ls.first_discovered_at,
+		       COALESCE(pf.flapping, false), pf.flapping_since
+		FROM latest_scans ls
+		JOIN scan_targets st ON ls.target_id = st.id
+		LEFT JOIN hosts ho ON ls.ip_address = ho.ip_address
+		` + flapJoin + `
+		WHERE 1 = 1
+	`
+
+	if openOnly {
+		query += " AND ls.status = 'open'"
+	}
+	if search != "" {
+		args = append(args, containsPattern(search))
+		param := "$" + strconv.Itoa(len(args))
+		query += ` AND (host(ls.ip_address) ILIKE ` + param + `
+			OR st.description ILIKE ` + param + `
+			OR array_to_string(ho.ptr_names, ' ') ILIKE ` + param + `
+			OR array_to_string(ho.cert_names, ' ') ILIKE ` + param + `)`
+	}
+
+	query += " ORDER BY ls.ip_address, ls.port, ls.protocol"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	results := []models.ScanResultWithTarget{}
@@ -148,24 +212,49 @@ func (h *ResultsHandler) GetOpenPorts(w http.ResponseWriter, r *http.Request) {
 		var result models.ScanResultWithTarget
 		err := rows.Scan(
 			&result.ID, &result.TargetID, &result.IPAddress, &result.Port,
-			&result.Status, &result.ScannedAt, &result.ResponseTimeMs,
+			&result.Protocol, &result.Status, &result.ScannedAt, &result.ResponseTimeMs,
+			&result.ServiceName, &result.ServiceProduct, &result.ServiceVersion,
+			&result.Engine, &result.Hostname,
 			&result.TargetDescription, &result.FirstDiscoveredAt,
+			&result.Flapping, &result.FlappingSince,
 		)
 		if err != nil {
-			http.Error(w, "Failed to parse results", http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		results = append(results, result)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
+	return results, rows.Err()
+}
+
+// sessionColumns is the column list scanned by scanSession
+const sessionColumns = `id, started_at, completed_at, targets_scanned, ports_scanned,
+	COALESCE(hosts_up, 0), COALESCE(hosts_down, 0),
+	COALESCE(open_ports, 0), COALESCE(new_ports, 0), COALESCE(closed_ports, 0), status,
+	COALESCE(cancel_reason, ''), COALESCE(cancelled_by, ''), COALESCE(skip_reason, ''), target_id`
+
+// scanSession reads a scan_sessions row selected with sessionColumns
+func scanSession(row rowScanner) (models.ScanSession, error) {
+	var session models.ScanSession
+	var targetID sql.NullInt64
+	err := row.Scan(
+		&session.ID, &session.StartedAt, &session.CompletedAt,
+		&session.TargetsScanned, &session.PortsScanned,
+		&session.HostsUp, &session.HostsDown,
+		&session.OpenPorts, &session.NewPorts, &session.ClosedPorts, &session.Status,
+		&session.CancelReason, &session.CancelledBy, &session.SkipReason, &targetID,
+	)
+	if targetID.Valid {
+		id := int(targetID.Int64)
+		session.TargetID = &id
+	}
+	return session, err
 }
 
 // GetScanSessions handles GET /api/v1/results/sessions
 func (h *ResultsHandler) GetScanSessions(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(`
-		SELECT id, started_at, completed_at, targets_scanned, ports_scanned, status
+		SELECT `+sessionColumns+`
 		FROM scan_sessions
 		ORDER BY started_at DESC
 		LIMIT 50
@@ -178,11 +267,7 @@ func (h *ResultsHandler) GetScanSessions(w http.ResponseWriter, r *http.Request)
 
 	sessions := []models.ScanSession{}
 	for rows.Next() {
-		var session models.ScanSession
-		err := rows.Scan(
-			&session.ID, &session.StartedAt, &session.CompletedAt,
-			&session.TargetsScanned, &session.PortsScanned, &session.Status,
-		)
+		session, err := scanSession(rows)
 		if err != nil {
 			http.Error(w, "Failed to parse sessions", http.StatusInternalServerError)
 			return
@@ -194,13 +279,146 @@ func (h *ResultsHandler) GetScanSessions(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(sessions)
 }
 
+// GetSession handles GET /api/v1/results/sessions/{id}
+// Returns a session's summary and findings: the ports open while it ran and
+// those it saw open or close. ?all=true returns the state of every port
+// known while it ran instead. Each finding is dated when its state began.
+// A session of a single target only covers that target's ports.
+func (h *ResultsHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	session, err := scanSession(h.db.QueryRow(`
+		SELECT `+sessionColumns+`
+		FROM scan_sessions
+		WHERE id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+		SELECT pe.id, pe.target_id, pe.ip_address, pe.port, pe.protocol,
+		       pe.status, pe.occurred_at, pe.response_time_ms,
+		       COALESCE(pe.service_name, ''), COALESCE(pe.service_product, ''), COALESCE(pe.service_version, ''),
+		       COALESCE(pe.engine, ''), CASE WHEN pe.session_id = $1 THEN COALESCE(pe.change, '') ELSE '' END,
+		       COALESCE(ho.ptr_names[1], ho.cert_names[1], ''),
+		       st.description as target_description
+		FROM port_events pe
+		JOIN scan_targets st ON pe.target_id = st.id
+		LEFT JOIN hosts ho ON pe.ip_address = ho.ip_address
+		JOIN scan_sessions ss ON ss.id = $1
+		-- Events whose state was in place while the session ran
+		WHERE pe.occurred_at <= COALESCE(ss.completed_at, NOW()) AND pe.last_seen_at >= ss.started_at
+		  AND (ss.target_id IS NULL OR pe.target_id = ss.target_id)
+	`
+	if r.URL.Query().Get("all") != "true" {
+		query += " AND (pe.status = 'open' OR (pe.session_id = $1 AND pe.change IS NOT NULL))"
+	}
+	query += " ORDER BY pe.ip_address, pe.port, pe.protocol"
+
+	rows, err := h.db.Query(query, id)
+	if err != nil {
+		http.Error(w, "Failed to fetch session results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	findings := models.SessionFindings{
+		ScanSession: session,
+		Results:     []models.ScanResultWithTarget{},
+	}
+	for rows.Next() {
+		var result models.ScanResultWithTarget
+		err := rows.Scan(
+			&result.ID, &result.TargetID, &result.IPAddress, &result.Port,
+			&result.Protocol, &result.Status, &result.ScannedAt, &result.ResponseTimeMs,
+			&result.ServiceName, &result.ServiceProduct, &result.ServiceVersion,
+			&result.Engine, &result.Change, &result.Hostname,
+			&result.TargetDescription,
+		)
+		if err != nil {
+			http.Error(w, "Failed to parse session results: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.SessionID = &session.ID
+		findings.Results = append(findings.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(findings)
+}
+
+// GetSessionHosts handles GET /api/v1/results/sessions/{id}/hosts
+// Returns the discovery outcome for each host in a session, optionally
+// filtered with ?state=up or ?state=down
+func (h *ResultsHandler) GetSessionHosts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	query := `
+		SELECT id, session_id, target_id, host(ip_address), is_up, method, discovered_at
+		FROM host_discovery
+		WHERE session_id = $1
+	`
+	switch r.URL.Query().Get("state") {
+	case "":
+	case "up":
+		query += " AND is_up = true"
+	case "down":
+		query += " AND is_up = false"
+	default:
+		http.Error(w, "Invalid state: must be up or down", http.StatusBadRequest)
+		return
+	}
+	query += " ORDER BY ip_address"
+
+	rows, err := h.db.Query(query, id)
+	if err != nil {
+		http.Error(w, "Failed to fetch session hosts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	hosts := []models.HostDiscovery{}
+	for rows.Next() {
+		var host models.HostDiscovery
+		err := rows.Scan(
+			&host.ID, &host.SessionID, &host.TargetID, &host.IPAddress,
+			&host.IsUp, &host.Method, &host.DiscoveredAt,
+		)
+		if err != nil {
+			http.Error(w, "Failed to parse session hosts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hosts = append(hosts, host)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hosts)
+}
+
 // PortChange represents a detected change in port status
 type PortChange struct {
 	IPAddress       string    `json:"ip_address"`
 	Port            int       `json:"port"`
+	Protocol        string    `json:"protocol"`
 	PreviousStatus  string    `json:"previous_status"`
 	NewStatus       string    `json:"new_status"`
-	ChangeType      string    `json:"change_type"` // "opened" or "closed"
+	ChangeType      string    `json:"change_type"` // "opened" or "closed" (no longer open)
 	DetectedAt      string    `json:"detected_at"`
 	TargetID        int       `json:"target_id"`
 	TargetDesc      string    `json:"target_description"`
@@ -212,33 +430,37 @@ func (h *ResultsHandler) GetChangeHistory(w http.ResponseWriter, r *http.Request
 	rows, err := h.db.Query(`
 		WITH ranked_results AS (
 			SELECT
-				sr.ip_address,
-				sr.port,
-				sr.status,
-				sr.scanned_at,
-				sr.target_id,
+				pe.ip_address,
+				pe.port,
+				pe.protocol,
+				pe.status,
+				pe.occurred_at as scanned_at,
+				pe.target_id,
 				st.description as target_description,
-				LAG(sr.status) OVER (PARTITION BY sr.ip_address, sr.port ORDER BY sr.scanned_at) as previous_status
-			FROM scan_results sr
-			JOIN scan_targets st ON sr.target_id = st.id
-			ORDER BY sr.scanned_at DESC
+				pe.previous_status
+			FROM port_events pe
+			JOIN scan_targets st ON pe.target_id = st.id
+			-- Only transitions into or out of 'open' are port events; moves
+			-- between closed, filtered and unreachable are ignored, and so is
+			-- open|filtered, a UDP probe that got no reply either way
+			WHERE (pe.previous_status = 'open' OR pe.status = 'open')
+			  AND pe.previous_status != 'open|filtered' AND pe.status != 'open|filtered'
 		)
 		SELECT
 			ip_address,
 			port,
+			protocol,
 			previous_status,
 			status as new_status,
 			CASE
-				WHEN previous_status = 'closed' AND status = 'open' THEN 'opened'
-				WHEN previous_status = 'open' AND status = 'closed' THEN 'closed'
-				ELSE 'unknown'
+				WHEN status = 'open' THEN 'opened'
+				ELSE 'closed'
 			END as change_type,
 			scanned_at,
 			target_id,
 			target_description
 		FROM ranked_results
 		WHERE previous_status IS NOT NULL
-		  AND previous_status != status
 		ORDER BY scanned_at DESC
 		LIMIT 200
 	`)
@@ -256,6 +478,7 @@ func (h *ResultsHandler) GetChangeHistory(w http.ResponseWriter, r *http.Request
 		err := rows.Scan(
 			&change.IPAddress,
 			&change.Port,
+			&change.Protocol,
 			&previousStatus,
 			&change.NewStatus,
 			&change.ChangeType,
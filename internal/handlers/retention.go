@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"ip-scanner/internal/models"
+	"ip-scanner/internal/scheduler"
+)
+
+type RetentionHandler struct {
+	db        *sql.DB
+	retention *scheduler.RetentionScheduler
+}
+
+func NewRetentionHandler(db *sql.DB, retention *scheduler.RetentionScheduler) *RetentionHandler {
+	return &RetentionHandler{db: db, retention: retention}
+}
+
+// GetRetention handles GET /api/v1/admin/retention
+// Returns the retention policy and the latest run of the retention job with
+// the rows it pruned from each table
+func (h *RetentionHandler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	status := models.RetentionStatus{Policy: h.retention.Policy()}
+
+	var run models.RetentionRun
+	var rowsPruned []byte
+	err := h.db.QueryRow(`
+		SELECT id, started_at, completed_at, status, rows_pruned, COALESCE(error, '')
+		FROM retention_runs
+		ORDER BY started_at DESC
+		LIMIT 1
+	`).Scan(&run.ID, &run.StartedAt, &run.CompletedAt, &run.Status, &rowsPruned, &run.Error)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to fetch retention runs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(rowsPruned, &run.RowsPruned); err != nil {
+			http.Error(w, "Failed to parse retention run: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, n := range run.RowsPruned {
+			run.TotalPruned += n
+		}
+		status.LastRun = &run
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
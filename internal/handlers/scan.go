@@ -2,9 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"ip-scanner/internal/middleware"
+	"ip-scanner/internal/scanner"
 	"ip-scanner/internal/scheduler"
+
+	"github.com/gorilla/mux"
 )
 
 type ScanHandler struct {
@@ -17,8 +24,30 @@ func NewScanHandler(s *scheduler.Scheduler) *ScanHandler {
 	}
 }
 
+// ScanStatusResponse is the live progress of the running scans, in total
+// and per session. Only scanning is set when no scan is in progress.
 type ScanStatusResponse struct {
-	Scanning bool `json:"scanning"`
+	scheduler.Progress
+}
+
+// statusStreamInterval is how often the status stream checks for progress
+const statusStreamInterval = 1 * time.Second
+
+// statusStreamKeepAlive is how often a comment is sent when nothing changes,
+// so proxies don't close an idle stream
+const statusStreamKeepAlive = 15 * time.Second
+
+type CancelScanResponse struct {
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	SessionID  int    `json:"session_id,omitempty"`
+	SessionIDs []int  `json:"session_ids,omitempty"`
+}
+
+type EnginesResponse struct {
+	Engines      []string `json:"engines"`
+	Default      string   `json:"default"`
+	SYNAvailable bool     `json:"syn_available"`
 }
 
 type TriggerScanResponse struct {
@@ -31,12 +60,65 @@ func (h *ScanHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	response := ScanStatusResponse{
-		Scanning: h.scheduler.IsScanning(),
+		Progress: h.scheduler.Progress(),
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
+// StreamStatus streams scan progress as Server-Sent Events. A "progress"
+// event carrying the same JSON as GetStatus is sent on connect and whenever
+// the progress changes.
+func (h *ScanHandler) StreamStatus(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// The server's write timeout would otherwise cut the stream short
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(statusStreamInterval)
+	defer ticker.Stop()
+
+	var last []byte
+	lastSent := time.Now()
+
+	for {
+		data, err := json.Marshal(ScanStatusResponse{Progress: h.scheduler.Progress()})
+		if err != nil {
+			return
+		}
+
+		switch {
+		case string(data) != string(last):
+			_, err = fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+			last = data
+			lastSent = time.Now()
+		case time.Since(lastSent) >= statusStreamKeepAlive:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			lastSent = time.Now()
+		}
+		if err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
 // TriggerScan manually triggers a scan
 func (h *ScanHandler) TriggerScan(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -57,3 +139,71 @@ func (h *ScanHandler) TriggerScan(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetEngines lists the registered scan engines and the default TCP engine.
+// syn_available is false when SYN targets are falling back to connect scans.
+func (h *ScanHandler) GetEngines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := EnginesResponse{
+		Engines:      scanner.Engines(),
+		Default:      h.scheduler.DefaultEngine(),
+		SYNAvailable: scanner.SYNAvailable(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelScan stops every scan in progress. Results stored so far are kept
+// and each session is marked cancelled along with who cancelled it.
+func (h *ScanHandler) CancelScan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sessionIDs := h.scheduler.CancelScan(middleware.UsernameFromContext(r.Context()))
+
+	response := CancelScanResponse{
+		Success:    len(sessionIDs) > 0,
+		SessionIDs: sessionIDs,
+	}
+
+	if response.Success {
+		// session_id keeps naming the earliest, for clients of a single scan
+		response.SessionID = sessionIDs[0]
+		response.Message = "Scan cancellation requested"
+		w.WriteHeader(http.StatusOK)
+	} else {
+		response.Message = "No scan in progress"
+		w.WriteHeader(http.StatusConflict)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelSession stops the scan of a specific session, if it is running
+func (h *ScanHandler) CancelSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	success := h.scheduler.CancelSession(id, middleware.UsernameFromContext(r.Context()))
+
+	response := CancelScanResponse{
+		Success:   success,
+		SessionID: id,
+	}
+
+	if success {
+		response.Message = "Scan cancellation requested"
+		w.WriteHeader(http.StatusOK)
+	} else {
+		response.Message = "Session is not running"
+		w.WriteHeader(http.StatusConflict)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"ip-scanner/internal/models"
 	"ip-scanner/internal/scanner"
+	"ip-scanner/internal/scheduler"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 type TargetHandler struct {
@@ -20,6 +23,49 @@ func NewTargetHandler(db *sql.DB) *TargetHandler {
 	return &TargetHandler{db: db}
 }
 
+// targetColumns is the column list scanned by scanTarget
+const targetColumns = `id, target, description, enabled, scan_udp, port_profile_id, discovery_method, COALESCE(scan_engine, ''),
+	COALESCE(schedule, ''), last_scan_at, next_scan_at, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTarget reads a scan_targets row selected with targetColumns
+func scanTarget(row rowScanner) (models.ScanTarget, error) {
+	var target models.ScanTarget
+	var portProfileID sql.NullInt64
+	var lastScanAt, nextScanAt sql.NullTime
+
+	err := row.Scan(
+		&target.ID, &target.Target, &target.Description,
+		&target.Enabled, &target.ScanUDP, &portProfileID,
+		&target.DiscoveryMethod, &target.ScanEngine,
+		&target.Schedule, &lastScanAt, &nextScanAt, &target.CreatedAt, &target.UpdatedAt,
+	)
+	if err != nil {
+		return target, err
+	}
+
+	if portProfileID.Valid {
+		id := int(portProfileID.Int64)
+		target.PortProfileID = &id
+	}
+	if lastScanAt.Valid {
+		target.LastScanAt = &lastScanAt.Time
+	}
+	if nextScanAt.Valid {
+		target.NextScanAt = &nextScanAt.Time
+	}
+
+	if parsed, err := scanner.ParseTarget(target.Target); err == nil {
+		target.AddressCount = parsed.Count()
+	}
+
+	return target, nil
+}
+
 // CreateTarget handles POST /api/v1/targets
 func (h *TargetHandler) CreateTarget(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateTargetRequest
@@ -28,23 +74,42 @@ func (h *TargetHandler) CreateTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate the target (IP or CIDR)
-	_, err := scanner.ParseCIDR(req.Target)
+	// Validate the target (IP, CIDR, host name or a list of them) without expanding it
+	parsed, err := scanner.ParseTarget(req.Target)
 	if err != nil {
-		http.Error(w, "Invalid IP address or CIDR notation", http.StatusBadRequest)
+		http.Error(w, "Invalid target: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := parsed.CheckSize(req.AllowLarge); err != nil {
+		http.Error(w, err.Error()+" (set allow_large to override)", http.StatusBadRequest)
 		return
 	}
 
+	if req.DiscoveryMethod == "" {
+		req.DiscoveryMethod = scanner.DiscoveryNone
+	}
+	if !scanner.IsValidDiscoveryMethod(req.DiscoveryMethod) {
+		http.Error(w, "Invalid discovery method: "+req.DiscoveryMethod, http.StatusBadRequest)
+		return
+	}
+	if req.ScanEngine != "" && !scanner.IsTCPEngine(req.ScanEngine) {
+		http.Error(w, "Invalid scan engine: "+req.ScanEngine, http.StatusBadRequest)
+		return
+	}
+	if req.Schedule != "" {
+		if _, err := scheduler.ParseSchedule(req.Schedule); err != nil {
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Insert into database
-	var target models.ScanTarget
-	err = h.db.QueryRow(`
-		INSERT INTO scan_targets (target, description, enabled)
-		VALUES ($1, $2, true)
-		RETURNING id, target, description, enabled, created_at, updated_at
-	`, req.Target, req.Description).Scan(
-		&target.ID, &target.Target, &target.Description,
-		&target.Enabled, &target.CreatedAt, &target.UpdatedAt,
-	)
+	target, err := scanTarget(h.db.QueryRow(`
+		INSERT INTO scan_targets (target, description, enabled, scan_udp, port_profile_id, discovery_method, scan_engine, schedule)
+		VALUES ($1, $2, true, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
+		RETURNING `+targetColumns,
+		req.Target, req.Description, req.ScanUDP, req.PortProfileID, req.DiscoveryMethod, req.ScanEngine, strings.TrimSpace(req.Schedule),
+	))
 
 	if err != nil {
 		http.Error(w, "Failed to create target: "+err.Error(), http.StatusInternalServerError)
@@ -59,7 +124,7 @@ func (h *TargetHandler) CreateTarget(w http.ResponseWriter, r *http.Request) {
 // ListTargets handles GET /api/v1/targets
 func (h *TargetHandler) ListTargets(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(`
-		SELECT id, target, description, enabled, created_at, updated_at
+		SELECT ` + targetColumns + `
 		FROM scan_targets
 		ORDER BY created_at DESC
 	`)
@@ -71,11 +136,7 @@ func (h *TargetHandler) ListTargets(w http.ResponseWriter, r *http.Request) {
 
 	targets := []models.ScanTarget{}
 	for rows.Next() {
-		var target models.ScanTarget
-		err := rows.Scan(
-			&target.ID, &target.Target, &target.Description,
-			&target.Enabled, &target.CreatedAt, &target.UpdatedAt,
-		)
+		target, err := scanTarget(rows)
 		if err != nil {
 			http.Error(w, "Failed to parse targets", http.StatusInternalServerError)
 			return
@@ -120,16 +181,13 @@ func (h *TargetHandler) ToggleTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var target models.ScanTarget
-	err = h.db.QueryRow(`
+	target, err := scanTarget(h.db.QueryRow(`
 		UPDATE scan_targets
 		SET enabled = NOT enabled, updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, target, description, enabled, created_at, updated_at
-	`, id).Scan(
-		&target.ID, &target.Target, &target.Description,
-		&target.Enabled, &target.CreatedAt, &target.UpdatedAt,
-	)
+		RETURNING `+targetColumns,
+		id,
+	))
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Target not found", http.StatusNotFound)
@@ -143,3 +201,255 @@ func (h *TargetHandler) ToggleTarget(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(target)
 }
+
+// ToggleUDP handles PUT /api/v1/targets/{id}/udp
+func (h *TargetHandler) ToggleUDP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	target, err := scanTarget(h.db.QueryRow(`
+		UPDATE scan_targets
+		SET scan_udp = NOT scan_udp, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+targetColumns,
+		id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to toggle UDP scanning", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// AssignProfile handles PUT /api/v1/targets/{id}/profile
+// Sets the port profile a target is scanned with; null reverts to the common ports
+func (h *TargetHandler) AssignProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AssignProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	target, err := scanTarget(h.db.QueryRow(`
+		UPDATE scan_targets
+		SET port_profile_id = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+targetColumns,
+		req.PortProfileID, id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to assign port profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// SetDiscovery handles PUT /api/v1/targets/{id}/discovery
+// Sets how hosts are probed before port scanning; "none" scans every address
+func (h *TargetHandler) SetDiscovery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetDiscoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !scanner.IsValidDiscoveryMethod(req.DiscoveryMethod) {
+		http.Error(w, "Invalid discovery method: "+req.DiscoveryMethod, http.StatusBadRequest)
+		return
+	}
+
+	target, err := scanTarget(h.db.QueryRow(`
+		UPDATE scan_targets
+		SET discovery_method = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+targetColumns,
+		req.DiscoveryMethod, id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set discovery method: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// SetEngine handles PUT /api/v1/targets/{id}/engine
+// Sets the TCP scan engine for a target; an empty engine reverts to the default
+func (h *TargetHandler) SetEngine(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetEngineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ScanEngine != "" && !scanner.IsTCPEngine(req.ScanEngine) {
+		http.Error(w, "Invalid scan engine: "+req.ScanEngine, http.StatusBadRequest)
+		return
+	}
+
+	target, err := scanTarget(h.db.QueryRow(`
+		UPDATE scan_targets
+		SET scan_engine = NULLIF($1, ''), updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+targetColumns,
+		req.ScanEngine, id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set scan engine: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// SetSchedule handles PUT /api/v1/targets/{id}/schedule
+// Sets when a target is scanned, as a cron expression or an interval; an
+// empty schedule reverts to the default interval. The scheduler plans the
+// next run within a minute.
+func (h *TargetHandler) SetSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Schedule = strings.TrimSpace(req.Schedule)
+	if req.Schedule != "" {
+		if _, err := scheduler.ParseSchedule(req.Schedule); err != nil {
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	target, err := scanTarget(h.db.QueryRow(`
+		UPDATE scan_targets
+		SET schedule = NULLIF($1, ''), next_scan_at = NULL, updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+targetColumns,
+		req.Schedule, id,
+	))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, "Target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set schedule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// GetResolutions handles GET /api/v1/targets/{id}/resolutions
+// Returns what the target's host names resolved to in recent scans
+func (h *TargetHandler) GetResolutions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, session_id, target_id, hostname,
+		       ARRAY(SELECT host(a) FROM unnest(addresses) AS a ORDER BY a),
+		       COALESCE(error, ''), resolved_at
+		FROM dns_resolutions
+		WHERE target_id = $1
+		ORDER BY resolved_at DESC, hostname
+		LIMIT 200
+	`, id)
+	if err != nil {
+		http.Error(w, "Failed to fetch resolutions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	resolutions := []models.DNSResolution{}
+	for rows.Next() {
+		var res models.DNSResolution
+		var sessionID sql.NullInt64
+
+		err := rows.Scan(
+			&res.ID, &sessionID, &res.TargetID, &res.Hostname,
+			pq.Array(&res.Addresses), &res.Error, &res.ResolvedAt,
+		)
+		if err != nil {
+			http.Error(w, "Failed to parse resolutions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if sessionID.Valid {
+			sessionIDInt := int(sessionID.Int64)
+			res.SessionID = &sessionIDInt
+		}
+		if res.Addresses == nil {
+			res.Addresses = []string{}
+		}
+
+		resolutions = append(resolutions, res)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resolutions)
+}
@@ -74,6 +74,26 @@ func JWTAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// UsernameFromContext returns the name of the authenticated user stored in ctx
+// by JWTAuthMiddleware, or an empty string if there is none
+func UsernameFromContext(ctx context.Context) string {
+	claims, ok := ctx.Value("user").(*AzureADClaims)
+	if !ok || claims == nil {
+		return ""
+	}
+
+	switch {
+	case claims.PreferredUsername != "":
+		return claims.PreferredUsername
+	case claims.Email != "":
+		return claims.Email
+	case claims.Name != "":
+		return claims.Name
+	default:
+		return claims.Subject
+	}
+}
+
 func validateToken(tokenString string) (*AzureADClaims, error) {
 	keycloakURL := os.Getenv("KEYCLOAK_URL")
 	realm := os.Getenv("KEYCLOAK_REALM")
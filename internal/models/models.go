@@ -1,14 +1,25 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type ScanTarget struct {
-	ID          int       `json:"id"`
-	Target      string    `json:"target"`
-	Description string    `json:"description"`
-	Enabled     bool      `json:"enabled"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              int        `json:"id"`
+	Target          string     `json:"target"`
+	Description     string     `json:"description"`
+	Enabled         bool       `json:"enabled"`
+	ScanUDP         bool       `json:"scan_udp"`
+	PortProfileID   *int       `json:"port_profile_id,omitempty"`
+	DiscoveryMethod string     `json:"discovery_method"`
+	ScanEngine      string     `json:"scan_engine,omitempty"` // empty uses the default engine
+	Schedule        string     `json:"schedule,omitempty"`    // empty uses the default interval
+	LastScanAt      *time.Time `json:"last_scan_at,omitempty"`
+	NextScanAt      *time.Time `json:"next_scan_at,omitempty"`
+	AddressCount    uint64     `json:"address_count"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 type ScanResult struct {
@@ -16,9 +27,16 @@ type ScanResult struct {
 	TargetID       int       `json:"target_id"`
 	IPAddress      string    `json:"ip_address"`
 	Port           int       `json:"port"`
+	Protocol       string    `json:"protocol"`
 	Status         string    `json:"status"`
 	ScannedAt      time.Time `json:"scanned_at"`
 	ResponseTimeMs int       `json:"response_time_ms"`
+	ServiceName    string    `json:"service_name,omitempty"`
+	ServiceProduct string    `json:"service_product,omitempty"`
+	ServiceVersion string    `json:"service_version,omitempty"`
+	Engine         string    `json:"engine,omitempty"`
+	SessionID      *int      `json:"session_id,omitempty"`
+	Change         string    `json:"change,omitempty"` // 'new' or 'closed' since the previous scan
 }
 
 type ScanSession struct {
@@ -27,18 +45,71 @@ type ScanSession struct {
 	CompletedAt    *time.Time `json:"completed_at,omitempty"`
 	TargetsScanned int       `json:"targets_scanned"`
 	PortsScanned   int       `json:"ports_scanned"`
+	HostsUp        int       `json:"hosts_up"`
+	HostsDown      int       `json:"hosts_down"`
+	OpenPorts      int       `json:"open_ports"`
+	NewPorts       int       `json:"new_ports"`
+	ClosedPorts    int       `json:"closed_ports"`
 	Status         string    `json:"status"`
+	CancelReason   string    `json:"cancel_reason,omitempty"`
+	CancelledBy    string    `json:"cancelled_by,omitempty"`
+	SkipReason     string    `json:"skip_reason,omitempty"`
+	TargetID       *int      `json:"target_id,omitempty"` // the target scanned; unset for sessions of several targets
 }
 
 type CreateTargetRequest struct {
-	Target      string `json:"target"`
+	Target          string `json:"target"`
+	Description     string `json:"description"`
+	ScanUDP         bool   `json:"scan_udp"`
+	PortProfileID   *int   `json:"port_profile_id,omitempty"`
+	DiscoveryMethod string `json:"discovery_method,omitempty"` // defaults to none
+	ScanEngine      string `json:"scan_engine,omitempty"`      // defaults to TCP_SCAN_ENGINE
+	Schedule        string `json:"schedule,omitempty"`         // cron expression or interval; defaults to the scan interval
+	AllowLarge      bool   `json:"allow_large"`                // override the target size guard
+}
+
+type PortProfile struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Ports       string    `json:"ports"`
+	UDPPorts    string    `json:"udp_ports,omitempty"`
+	PortCount   int       `json:"port_count"`
+	Builtin     bool      `json:"builtin"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type PortProfileRequest struct {
+	Name        string `json:"name"`
 	Description string `json:"description"`
+	Ports       string `json:"ports"`
+	UDPPorts    string `json:"udp_ports"`
+}
+
+type AssignProfileRequest struct {
+	PortProfileID *int `json:"port_profile_id"`
+}
+
+type SetDiscoveryRequest struct {
+	DiscoveryMethod string `json:"discovery_method"`
+}
+
+type SetEngineRequest struct {
+	ScanEngine string `json:"scan_engine"`
+}
+
+type SetScheduleRequest struct {
+	Schedule string `json:"schedule"`
 }
 
 type ScanResultWithTarget struct {
 	ScanResult
+	Hostname          string     `json:"hostname,omitempty"`
 	TargetDescription string     `json:"target_description"`
 	FirstDiscoveredAt *time.Time `json:"first_discovered_at,omitempty"`
+	Flapping          bool       `json:"flapping,omitempty"`
+	FlappingSince     *time.Time `json:"flapping_since,omitempty"`
 }
 
 type AWSCredentials struct {
@@ -76,6 +147,162 @@ type Notification struct {
 	IPAddress   string    `json:"ip_address,omitempty"`
 	Port        *int      `json:"port,omitempty"`
 	TargetID    *int      `json:"target_id,omitempty"`
+	SessionID   *int      `json:"session_id,omitempty"`
 	IsRead      bool      `json:"is_read"`
 	CreatedAt   time.Time `json:"created_at"`
 }
+
+type TLSCertificate struct {
+	ID                int             `json:"id"`
+	TargetID          *int            `json:"target_id,omitempty"`
+	IPAddress         string          `json:"ip_address"`
+	Port              int             `json:"port"`
+	Subject           string          `json:"subject"`
+	Issuer            string          `json:"issuer"`
+	SANs              []string        `json:"sans"`
+	NotBefore         time.Time       `json:"not_before"`
+	NotAfter          time.Time       `json:"not_after"`
+	DaysRemaining     int             `json:"days_remaining"`
+	KeyType           string          `json:"key_type"`
+	KeySize           int             `json:"key_size"`
+	SelfSigned        bool            `json:"self_signed"`
+	TLSVersion        string          `json:"tls_version"`
+	CipherSuite       string          `json:"cipher_suite"`
+	FingerprintSHA256 string          `json:"fingerprint_sha256"`
+	Chain             json.RawMessage `json:"chain"`
+	FirstSeenAt       time.Time       `json:"first_seen_at"`
+	LastSeenAt        time.Time       `json:"last_seen_at"`
+}
+
+type DNSResolution struct {
+	ID         int       `json:"id"`
+	SessionID  *int      `json:"session_id,omitempty"`
+	TargetID   int       `json:"target_id"`
+	Hostname   string    `json:"hostname"`
+	Addresses  []string  `json:"addresses"`
+	Error      string    `json:"error,omitempty"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+type Host struct {
+	IPAddress     string     `json:"ip_address"`
+	Hostname      string     `json:"hostname,omitempty"`
+	PTRNames      []string   `json:"ptr_names"`
+	CertNames     []string   `json:"cert_names"`
+	PTRResolvedAt *time.Time `json:"ptr_resolved_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type HostDiscovery struct {
+	ID           int       `json:"id"`
+	SessionID    int       `json:"session_id"`
+	TargetID     int       `json:"target_id"`
+	IPAddress    string    `json:"ip_address"`
+	IsUp         bool      `json:"is_up"`
+	Method       string    `json:"method"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+type AdhocScanRequest struct {
+	Target     string `json:"target"`
+	Ports      string `json:"ports,omitempty"`       // e.g. "22,80,443"; defaults to the common ports
+	UDPPorts   string `json:"udp_ports,omitempty"`   // UDP is only scanned when set
+	ScanEngine string `json:"scan_engine,omitempty"` // defaults to TCP_SCAN_ENGINE
+}
+
+type AdhocResult struct {
+	IPAddress      string `json:"ip_address"`
+	Port           int    `json:"port"`
+	Protocol       string `json:"protocol"`
+	Status         string `json:"status"`
+	ResponseTimeMs int    `json:"response_time_ms"`
+	ServiceName    string `json:"service_name,omitempty"`
+	ServiceProduct string `json:"service_product,omitempty"`
+	ServiceVersion string `json:"service_version,omitempty"`
+	Engine         string `json:"engine"`
+}
+
+type AdhocScan struct {
+	ID          int           `json:"id,omitempty"` // only set for jobs run in the background
+	Target      string        `json:"target"`
+	Ports       []int         `json:"ports"`
+	UDPPorts    []int         `json:"udp_ports"`
+	Engine      string        `json:"engine"`
+	Status      string        `json:"status"`
+	RequestedBy string        `json:"requested_by,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Results     []AdhocResult `json:"results"`
+	CreatedAt   time.Time     `json:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+}
+
+type BlackoutWindow struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	TargetID        *int       `json:"target_id,omitempty"`  // nil applies to every target
+	Recurrence      string     `json:"recurrence,omitempty"` // cron expression for when the window opens
+	DurationMinutes int        `json:"duration_minutes,omitempty"`
+	Timezone        string     `json:"timezone"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"` // one-off window
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	Action          string     `json:"action"` // 'skip' or 'defer'
+	Enabled         bool       `json:"enabled"`
+	Active          bool       `json:"active"`
+	ActiveUntil     *time.Time `json:"active_until,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type BlackoutWindowRequest struct {
+	Name            string     `json:"name"`
+	TargetID        *int       `json:"target_id,omitempty"`
+	Recurrence      string     `json:"recurrence,omitempty"`
+	DurationMinutes int        `json:"duration_minutes,omitempty"`
+	Timezone        string     `json:"timezone,omitempty"` // defaults to UTC
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	Action          string     `json:"action,omitempty"` // defaults to defer
+}
+
+type SessionFindings struct {
+	ScanSession
+	Results []ScanResultWithTarget `json:"results"`
+}
+
+type DiffPort struct {
+	IPAddress      string `json:"ip_address"`
+	Port           int    `json:"port"`
+	Protocol       string `json:"protocol"`
+	PreviousStatus string `json:"previous_status,omitempty"` // empty if never scanned before
+	Status         string `json:"status"`
+	TargetID       int    `json:"target_id"`
+}
+
+type SessionDiff struct {
+	From             ScanSession `json:"from"`
+	To               ScanSession `json:"to"`
+	PortsOpened      []DiffPort  `json:"ports_opened"`
+	PortsClosed      []DiffPort  `json:"ports_closed"`
+	HostsAppeared    []string    `json:"hosts_appeared"`    // hosts with open ports in to but none in from
+	HostsDisappeared []string    `json:"hosts_disappeared"` // hosts with open ports in from but none in to
+}
+
+type RetentionPolicy struct {
+	RawDays          int `json:"raw_days"`          // port history, discovery, DNS and sessions; 0 keeps them
+	RollupDays       int `json:"rollup_days"`       // daily rollups; 0 keeps them
+	NotificationDays int `json:"notification_days"` // 0 keeps them
+}
+
+type RetentionRun struct {
+	ID          int              `json:"id"`
+	StartedAt   time.Time        `json:"started_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Status      string           `json:"status"`
+	RowsPruned  map[string]int64 `json:"rows_pruned"` // by table
+	TotalPruned int64            `json:"total_pruned"`
+	Error       string           `json:"error,omitempty"`
+}
+
+type RetentionStatus struct {
+	Policy  RetentionPolicy `json:"policy"`
+	LastRun *RetentionRun   `json:"last_run"` // null until the job first runs
+}
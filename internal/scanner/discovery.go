@@ -0,0 +1,238 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Host discovery methods, configurable per target
+const (
+	DiscoveryNone = "none" // port scan every address
+	DiscoveryICMP = "icmp" // ICMP echo, needs CAP_NET_RAW
+	DiscoveryTCP  = "tcp"  // TCP connect ping to a few common ports
+	DiscoveryARP  = "arp"  // ARP resolution, only for directly connected IPv4 subnets
+	DiscoveryAuto = "auto" // ICMP where permitted, then ARP on local subnets, then TCP
+)
+
+// DiscoveryMethods lists the valid discovery methods
+var DiscoveryMethods = []string{DiscoveryNone, DiscoveryICMP, DiscoveryTCP, DiscoveryARP, DiscoveryAuto}
+
+// tcpPingPorts are tried by the TCP ping. A reset counts as proof of life
+// just as much as an accepted connection.
+var tcpPingPorts = []int{80, 443, 22, 3389}
+
+// DiscoveryResult reports whether a host answered and which method proved it
+type DiscoveryResult struct {
+	IP     string
+	Up     bool
+	Method string
+}
+
+// IsValidDiscoveryMethod reports whether method is one of DiscoveryMethods
+func IsValidDiscoveryMethod(method string) bool {
+	for _, m := range DiscoveryMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverHost checks whether ip is alive using the given method
+func DiscoverHost(ctx context.Context, ip string, method string, timeout time.Duration) DiscoveryResult {
+	result := DiscoveryResult{IP: ip, Method: method}
+
+	switch method {
+	case DiscoveryNone, "":
+		result.Up = true
+	case DiscoveryICMP:
+		up, err := pingICMP(ctx, ip, timeout)
+		if err != nil {
+			// Raw sockets not permitted; fall back rather than report every host down
+			result.Method = DiscoveryTCP
+			result.Up = pingTCP(ctx, ip, timeout)
+		} else {
+			result.Up = up
+		}
+	case DiscoveryTCP:
+		result.Up = pingTCP(ctx, ip, timeout)
+	case DiscoveryARP:
+		up, err := pingARP(ctx, ip, timeout)
+		if err != nil {
+			result.Method = DiscoveryTCP
+			result.Up = pingTCP(ctx, ip, timeout)
+		} else {
+			result.Up = up
+		}
+	case DiscoveryAuto:
+		if up, err := pingICMP(ctx, ip, timeout); err == nil && up {
+			result.Up, result.Method = true, DiscoveryICMP
+			return result
+		}
+		if up, err := pingARP(ctx, ip, timeout); err == nil && up {
+			result.Up, result.Method = true, DiscoveryARP
+			return result
+		}
+		result.Up, result.Method = pingTCP(ctx, ip, timeout), DiscoveryTCP
+	}
+
+	return result
+}
+
+// pingTCP connects to a handful of common ports concurrently; any answer,
+// accepted or refused, means the host is up
+func pingTCP(ctx context.Context, ip string, timeout time.Duration) bool {
+	results := ScanIPParallel(ctx, ip, tcpPingPorts, timeout)
+	for _, r := range results {
+		if r.Status == StatusOpen || r.Status == StatusClosed {
+			return true
+		}
+	}
+	return false
+}
+
+// pingICMP sends a single ICMP echo request. An error means raw sockets
+// aren't available, not that the host is down.
+func pingICMP(ctx context.Context, ip string, timeout time.Duration) (bool, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	network, echoRequest, echoReply := "ip4:icmp", byte(8), byte(0)
+	if addr.To4() == nil {
+		network, echoRequest, echoReply = "ip6:ipv6-icmp", 128, 129
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, ip)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	id := uint16(os.Getpid() & 0xffff)
+	msg := []byte{echoRequest, 0, 0, 0, 0, 0, 0, 1}
+	binary.BigEndian.PutUint16(msg[4:], id)
+	msg = append(msg, "ip-scanner"...)
+	if echoRequest == 8 {
+		// The kernel fills in the checksum for ICMPv6 but not for ICMPv4
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return false, err
+	}
+
+	ipConn := conn.(*net.IPConn)
+	deadline := time.Now().Add(timeout)
+	ipConn.SetReadDeadline(deadline)
+	buf := make([]byte, 1500)
+
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		// ReadFrom strips the IPv4 header, Read does not
+		n, _, err := ipConn.ReadFrom(buf)
+		if err != nil {
+			return false, nil
+		}
+		if n >= 8 && buf[0] == echoReply && binary.BigEndian.Uint16(buf[4:]) == id {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// pingARP checks whether an IPv4 address on a directly connected subnet
+// answers ARP. A datagram is sent to make the kernel resolve the address,
+// then the neighbour table is polled. An error means ARP can't be used for
+// this address, not that the host is down.
+func pingARP(ctx context.Context, ip string, timeout time.Duration) (bool, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || addr.To4() == nil {
+		return false, errors.New("ARP discovery only supports IPv4")
+	}
+	if !isOnLocalSubnet(addr) {
+		return false, errors.New("address is not on a directly connected subnet")
+	}
+
+	conn, err := net.Dial("udp4", net.JoinHostPort(ip, "9"))
+	if err != nil {
+		return false, err
+	}
+	conn.Write([]byte{0})
+	conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		up, err := arpEntryComplete(ip)
+		if err != nil {
+			return false, err
+		}
+		if up || time.Now().After(deadline) {
+			return up, nil
+		}
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return false, nil
+		}
+	}
+}
+
+// isOnLocalSubnet reports whether addr belongs to a network one of our
+// interfaces is directly attached to
+func isOnLocalSubnet(addr net.IP) bool {
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range ifaceAddrs {
+		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// arpEntryComplete looks ip up in the Linux ARP table
+func arpEntryComplete(ip string) (bool, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// IP address  HW type  Flags  HW address  Mask  Device
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 4 && fields[0] == ip {
+			// ATF_COM (0x2) is set once the entry has resolved
+			return fields[2] != "0x0" && fields[3] != "00:00:00:00:00:00", nil
+		}
+	}
+	return false, scanner.Err()
+}
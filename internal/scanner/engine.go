@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Options tune how an engine probes ports
+type Options struct {
+	Timeout     time.Duration // per probe
+	Retries     int           // extra probes for ports that didn't answer
+	Concurrency int           // ports of one IP probed at once
+}
+
+// DefaultOptions matches the behaviour of the package level scan functions
+func DefaultOptions() Options {
+	return Options{
+		Timeout:     2 * time.Second,
+		Retries:     0,
+		Concurrency: maxConcurrentPorts,
+	}
+}
+
+// Scanner is a port scanning engine. Implementations return one result per
+// port, in the same order as ports. When ctx is cancelled they stop early and
+// return ctx.Err() along with the results of the ports they finished probing.
+type Scanner interface {
+	Name() string
+	ScanIP(ctx context.Context, ip string, ports []int, opts Options) ([]PortScanResult, error)
+}
+
+var (
+	enginesMu sync.RWMutex
+	engines   = map[string]Scanner{}
+)
+
+func init() {
+	Register(ConnectScanner{})
+	Register(SYNScanner{})
+	Register(UDPScanner{})
+}
+
+// Register adds an engine to the registry, replacing any engine with the
+// same name
+func Register(s Scanner) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	engines[s.Name()] = s
+}
+
+// Lookup returns the engine registered under name
+func Lookup(name string) (Scanner, error) {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	s, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scan engine: %s", name)
+	}
+	return s, nil
+}
+
+// Engines returns the names of all registered engines
+func Engines() []string {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsTCPEngine reports whether name is a registered engine that can scan
+// TCP ports for a target
+func IsTCPEngine(name string) bool {
+	if name == EngineUDP {
+		return false
+	}
+	_, err := Lookup(name)
+	return err == nil
+}
+
+// unanswered reports whether a probe got no answer and is worth retrying
+func unanswered(result PortScanResult) bool {
+	return result.Status == StatusFiltered || result.Status == StatusOpenFiltered
+}
+
+// withRetries repeats probe until the port answers or retries run out
+func withRetries(ctx context.Context, retries int, probe func() PortScanResult) PortScanResult {
+	result := probe()
+	for i := 0; i < retries && unanswered(result) && ctx.Err() == nil; i++ {
+		result = probe()
+	}
+	return result
+}
+
+// ConnectScanner scans TCP ports with full connects through the OS
+type ConnectScanner struct{}
+
+func (ConnectScanner) Name() string { return EngineConnect }
+
+func (ConnectScanner) ScanIP(ctx context.Context, ip string, ports []int, opts Options) ([]PortScanResult, error) {
+	return scanParallel(ctx, ip, ports, opts.Concurrency, func(ctx context.Context, ip string, port int) PortScanResult {
+		return withRetries(ctx, opts.Retries, func() PortScanResult {
+			return ScanPort(ctx, ip, port, opts.Timeout)
+		})
+	})
+}
+
+// SYNScanner scans TCP ports with half-open SYN probes, falling back to
+// ConnectScanner when raw sockets aren't available
+type SYNScanner struct{}
+
+func (SYNScanner) Name() string { return EngineSYN }
+
+func (SYNScanner) ScanIP(ctx context.Context, ip string, ports []int, opts Options) ([]PortScanResult, error) {
+	if !SYNAvailable() {
+		return ConnectScanner{}.ScanIP(ctx, ip, ports, opts)
+	}
+
+	attempts := opts.Retries + 1
+	if attempts < synProbeAttempts {
+		attempts = synProbeAttempts
+	}
+
+	results, err := synScan(ctx, ip, ports, opts.Timeout, attempts)
+	if err != nil && ctx.Err() == nil {
+		return ConnectScanner{}.ScanIP(ctx, ip, ports, opts)
+	}
+	return results, err
+}
+
+// UDPScanner probes UDP ports. A silent port gets at least udpProbeAttempts
+// probes, since a lost datagram isn't resent the way a SYN is.
+type UDPScanner struct{}
+
+func (UDPScanner) Name() string { return EngineUDP }
+
+func (UDPScanner) ScanIP(ctx context.Context, ip string, ports []int, opts Options) ([]PortScanResult, error) {
+	attempts := opts.Retries + 1
+	if attempts < udpProbeAttempts {
+		attempts = udpProbeAttempts
+	}
+
+	return scanParallel(ctx, ip, ports, opts.Concurrency, func(ctx context.Context, ip string, port int) PortScanResult {
+		return withRetries(ctx, attempts-1, func() PortScanResult {
+			return ScanUDPPort(ctx, ip, port, opts.Timeout)
+		})
+	})
+}
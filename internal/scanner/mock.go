@@ -0,0 +1,51 @@
+package scanner
+
+import (
+	"context"
+	"time"
+)
+
+// MockScanner is a fake engine that never touches the network. Ports listed
+// in OpenPorts for an IP, or under "*" for every IP, are reported open and
+// all others closed. It isn't registered by default, so real targets can't
+// select it; tests register a configured one.
+type MockScanner struct {
+	OpenPorts map[string][]int
+	Latency   time.Duration // simulated response time per IP
+}
+
+func (m *MockScanner) Name() string { return EngineMock }
+
+func (m *MockScanner) ScanIP(ctx context.Context, ip string, ports []int, opts Options) ([]PortScanResult, error) {
+	if m.Latency > 0 {
+		select {
+		case <-time.After(m.Latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	open := make(map[int]bool)
+	for _, key := range []string{ip, "*"} {
+		for _, port := range m.OpenPorts[key] {
+			open[port] = true
+		}
+	}
+
+	results := make([]PortScanResult, len(ports))
+	for i, port := range ports {
+		results[i] = PortScanResult{
+			IP:       ip,
+			Port:     port,
+			Protocol: ProtocolTCP,
+			Status:   StatusClosed,
+			Engine:   EngineMock,
+		}
+		if open[port] {
+			results[i].Status = StatusOpen
+			results[i].ResponseTimeMs = int(m.Latency.Milliseconds())
+		}
+	}
+
+	return results, ctx.Err()
+}
@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsePortSpec parses a port list such as "1-1024,3306,5432" into a sorted,
+// de-duplicated slice of ports
+func ParsePortSpec(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end := part, part
+		if idx := strings.Index(part, "-"); idx >= 0 {
+			start, end = strings.TrimSpace(part[:idx]), strings.TrimSpace(part[idx+1:])
+		}
+
+		low, err := parsePort(start)
+		if err != nil {
+			return nil, err
+		}
+		high, err := parsePort(end)
+		if err != nil {
+			return nil, err
+		}
+		if low > high {
+			return nil, fmt.Errorf("invalid port range: %s", part)
+		}
+
+		for port := low; port <= high; port++ {
+			seen[port] = true
+		}
+	}
+
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("port list is empty")
+	}
+
+	ports := make([]int, 0, len(seen))
+	for port := range seen {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port: %s", s)
+	}
+	return port, nil
+}
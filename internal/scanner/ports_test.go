@@ -0,0 +1,46 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{spec: "80", want: []int{80}},
+		{spec: "1-3,2,5", want: []int{1, 2, 3, 5}},
+		{spec: " 443 , 22 ", want: []int{22, 443}},
+		{spec: "10 - 12", want: []int{10, 11, 12}},
+		{spec: "3306,,5432", want: []int{3306, 5432}},
+		{spec: "65535", want: []int{65535}},
+		{spec: "", wantErr: true},
+		{spec: ",", wantErr: true},
+		{spec: "0", wantErr: true},
+		{spec: "65536", wantErr: true},
+		{spec: "http", wantErr: true},
+		{spec: "5-1", wantErr: true},
+		{spec: "1-", wantErr: true},
+		{spec: "22,abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePortSpec(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePortSpec(%q) = %v, want error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePortSpec(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePortSpec(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,43 @@
 package scanner
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net"
+	"strconv"
+	"syscall"
 	"time"
 )
 
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// Scan engines, recorded with each result
+const (
+	EngineConnect = "connect" // full TCP handshake via the OS
+	EngineSYN     = "syn"     // half-open scan over a raw socket
+	EngineUDP     = "udp"     // UDP probes
+	EngineMock    = "mock"    // fake results for tests, never touches the network
+)
+
+// Port states stored in port_state.status and port_events.status
+const (
+	StatusOpen         = "open"
+	StatusClosed       = "closed"        // host answered with a reset / port unreachable
+	StatusFiltered     = "filtered"      // no answer before the timeout, usually a firewall drop
+	StatusUnreachable  = "unreachable"   // host or network unreachable, or any other dial error
+	StatusOpenFiltered = "open|filtered" // UDP probe got no reply
+)
+
+// Determinate reports whether a status says if the port is open or not. A
+// UDP probe without a reply (open|filtered) says neither, so it never counts
+// as a move into or out of open.
+func Determinate(status string) bool {
+	return status != StatusOpenFiltered
+}
+
 var CommonPorts = []int{
 	21,    // FTP
 	22,    // SSH
@@ -26,52 +58,97 @@ var CommonPorts = []int{
 type PortScanResult struct {
 	IP             string
 	Port           int
+	Protocol       string
 	Status         string
 	ResponseTimeMs int
+	Engine         string
+	Service        ServiceInfo
+	TLS            *CertificateInfo
 }
 
 // ScanPort checks if a specific port is open on an IP address
-func ScanPort(ip string, port int, timeout time.Duration) PortScanResult {
+func ScanPort(ctx context.Context, ip string, port int, timeout time.Duration) PortScanResult {
 	result := PortScanResult{
-		IP:     ip,
-		Port:   port,
-		Status: "closed",
+		IP:       ip,
+		Port:     port,
+		Protocol: ProtocolTCP,
+		Status:   StatusClosed,
+		Engine:   EngineConnect,
 	}
 
-	target := fmt.Sprintf("%s:%d", ip, port)
+	target := net.JoinHostPort(ip, strconv.Itoa(port))
 	start := time.Now()
 
-	conn, err := net.DialTimeout("tcp", target, timeout)
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
 	elapsed := time.Since(start)
 
 	if err != nil {
-		// Port is closed or filtered
-		result.Status = "closed"
+		result.Status = classifyDialError(err)
 		return result
 	}
 
 	conn.Close()
-	result.Status = "open"
+	result.Status = StatusOpen
 	result.ResponseTimeMs = int(elapsed.Milliseconds())
 
 	return result
 }
 
-// ScanIP scans all common ports on a single IP address (sequential)
-func ScanIP(ip string, ports []int, timeout time.Duration) []PortScanResult {
+// classifyDialError maps a failed TCP connect onto a port state: a refused
+// connection means the host answered and nothing listens, a timeout means
+// the SYN was dropped somewhere, anything else means we never reached it
+func classifyDialError(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return StatusClosed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return StatusFiltered
+	default:
+		return StatusUnreachable
+	}
+}
+
+// ScanIP scans all common ports on a single IP address (sequential).
+// It stops early if ctx is cancelled.
+func ScanIP(ctx context.Context, ip string, ports []int, timeout time.Duration) []PortScanResult {
 	results := make([]PortScanResult, 0, len(ports))
 
 	for _, port := range ports {
-		result := ScanPort(ip, port, timeout)
+		result := ScanPort(ctx, ip, port, timeout)
+		if ctx.Err() != nil {
+			break
+		}
 		results = append(results, result)
 	}
 
 	return results
 }
 
-// ScanIPParallel scans all ports on a single IP address concurrently
-func ScanIPParallel(ip string, ports []int, timeout time.Duration) []PortScanResult {
+// ScanIPParallel scans all ports on a single IP address concurrently.
+// If ctx is cancelled only the ports that finished are returned.
+func ScanIPParallel(ctx context.Context, ip string, ports []int, timeout time.Duration) []PortScanResult {
+	results, _ := scanParallel(ctx, ip, ports, maxConcurrentPorts,
+		func(ctx context.Context, ip string, port int) PortScanResult {
+			return ScanPort(ctx, ip, port, timeout)
+		})
+	return results
+}
+
+// maxConcurrentPorts caps how many ports of a single IP are probed at once so
+// large port profiles don't open tens of thousands of sockets
+const maxConcurrentPorts = 256
+
+// scanParallel runs scanFn against every port, at most concurrency at a time,
+// and returns the results in the same order as ports. If ctx is cancelled no
+// further ports are started and ctx.Err() is returned with partial results.
+func scanParallel(ctx context.Context, ip string, ports []int, concurrency int, scanFn func(context.Context, string, int) PortScanResult) ([]PortScanResult, error) {
 	results := make([]PortScanResult, len(ports))
+	if concurrency <= 0 {
+		concurrency = maxConcurrentPorts
+	}
 
 	// Use a channel to collect results
 	resultChan := make(chan struct {
@@ -79,10 +156,27 @@ func ScanIPParallel(ip string, ports []int, timeout time.Duration) []PortScanRes
 		result PortScanResult
 	}, len(ports))
 
+	sem := make(chan struct{}, concurrency)
+
 	// Scan each port concurrently
+	started := 0
 	for i, port := range ports {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		started++
 		go func(index int, p int) {
-			result := ScanPort(ip, p, timeout)
+			defer func() { <-sem }()
+			result := scanFn(ctx, ip, p)
+			if ctx.Err() != nil {
+				// A probe interrupted by cancellation says nothing about the port
+				result = PortScanResult{}
+			}
 			resultChan <- struct {
 				index  int
 				result PortScanResult
@@ -91,64 +185,21 @@ func ScanIPParallel(ip string, ports []int, timeout time.Duration) []PortScanRes
 	}
 
 	// Collect all results
-	for i := 0; i < len(ports); i++ {
+	for i := 0; i < started; i++ {
 		res := <-resultChan
 		results[res.index] = res.result
 	}
 
-	return results
-}
-
-// ParseCIDR parses a CIDR notation and returns all IP addresses in the range
-func ParseCIDR(cidr string) ([]string, error) {
-	// Check if it's a single IP or CIDR
-	if !contains(cidr, "/") {
-		// Single IP address
-		ip := net.ParseIP(cidr)
-		if ip == nil {
-			return nil, fmt.Errorf("invalid IP address: %s", cidr)
+	if err := ctx.Err(); err != nil {
+		// Drop ports that were never started or were cut short
+		probed := results[:0]
+		for _, r := range results {
+			if r.IP != "" {
+				probed = append(probed, r)
+			}
 		}
-		return []string{cidr}, nil
-	}
-
-	// Parse CIDR
-	ip, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return nil, err
-	}
-
-	var ips []string
-	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
-		ips = append(ips, ip.String())
-	}
-
-	// Remove network and broadcast addresses for networks smaller than /31
-	if len(ips) > 2 {
-		ips = ips[1 : len(ips)-1]
+		return probed, err
 	}
 
-	return ips, nil
-}
-
-// Helper function to increment IP address
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
-
-// Helper function to check if string contains substring
-func contains(s, substr string) bool {
-	for i := 0; i < len(s); i++ {
-		if i+len(substr) > len(s) {
-			return false
-		}
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
+	return results, nil
 }
@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyDialError(t *testing.T) {
+	dialErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"refused", dialErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)), StatusClosed},
+		{"reset", dialErr(os.NewSyscallError("connect", syscall.ECONNRESET)), StatusClosed},
+		{"timed out", dialErr(os.NewSyscallError("connect", syscall.ETIMEDOUT)), StatusFiltered},
+		{"dial timeout", dialErr(context.DeadlineExceeded), StatusFiltered},
+		{"no route", dialErr(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), StatusUnreachable},
+		{"network down", dialErr(os.NewSyscallError("connect", syscall.ENETUNREACH)), StatusUnreachable},
+		{"other", errors.New("something else"), StatusUnreachable},
+	}
+
+	for _, tt := range tests {
+		if got := classifyDialError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyDialError() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
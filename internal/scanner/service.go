@@ -0,0 +1,362 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceInfo describes what answered on an open port
+type ServiceInfo struct {
+	Name    string
+	Product string
+	Version string
+}
+
+// tlsPorts are tried with a TLS handshake before any plaintext probe
+var tlsPorts = map[int]bool{
+	443:  true,
+	465:  true,
+	636:  true,
+	993:  true,
+	995:  true,
+	8443: true,
+}
+
+// udpServiceNames maps the UDP ports we send dedicated probes to onto the
+// service that answered them
+var udpServiceNames = map[int]string{
+	53:    "dns",
+	123:   "ntp",
+	161:   "snmp",
+	500:   "ike",
+	1900:  "ssdp",
+	11211: "memcached",
+}
+
+var (
+	// "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1"
+	sshBannerRe = regexp.MustCompile(`^SSH-[\d.]+-([^_\s-]+)[_-]?(\S*)`)
+	// "nginx/1.18.0 (Ubuntu)", "Apache/2.4.41"
+	productVersionRe = regexp.MustCompile(`([A-Za-z][\w.-]*?)[/ ]v?(\d+(?:\.\d+)+[\w.-]*)`)
+	// Known FTP and SMTP daemons that put their name in the 220 greeting
+	greetingProducts = []string{
+		"vsFTPd", "ProFTPD", "Pure-FTPd", "FileZilla Server", "Microsoft FTP Service",
+		"Postfix", "Exim", "Sendmail", "Microsoft ESMTP MAIL Service", "OpenSMTPD",
+	}
+	redisVersionRe = regexp.MustCompile(`redis_version:(\S+)`)
+)
+
+// serviceProbeTimeout caps each probe DetectService sends. A port that stays
+// silent costs one wait per probe, so the full scan timeout is far too long.
+const serviceProbeTimeout = 2 * time.Second
+
+// probeOutcome says whether a probe settled what runs on a port
+type probeOutcome int
+
+const (
+	// probeSilent means the port accepted the connection but gave nothing
+	// away; the next probe may still get an answer
+	probeSilent probeOutcome = iota
+	// probeIdentified means the reply named the service
+	probeIdentified
+	// probeAnswered means the port replied with something we don't
+	// recognise, which no other probe will improve on
+	probeAnswered
+	// probeUnreachable means the dial failed, and so would any other probe
+	probeUnreachable
+)
+
+// tlsAlertRecord is the first byte of a TLS alert, which TLS servers send
+// back when a plaintext probe arrives instead of a ClientHello
+const tlsAlertRecord = 0x15
+
+// DetectService fingerprints the service behind an open TCP port and, when
+// the port speaks TLS, returns the certificate chain from the same handshake.
+//
+// Banner-first protocols (SSH, FTP, SMTP, MySQL) are identified from whatever
+// the server sends on connect. If the server stays quiet, light probes are
+// sent in turn: TLS ClientHello, Redis PING and an HTTP HEAD request. Probing
+// stops at the first conclusive answer, and each probe waits at most
+// serviceProbeTimeout.
+func DetectService(ctx context.Context, ip string, port int, timeout time.Duration) (ServiceInfo, *CertificateInfo) {
+	target := net.JoinHostPort(ip, strconv.Itoa(port))
+	timeout = min(timeout, serviceProbeTimeout)
+
+	if tlsPorts[port] {
+		info, cert, outcome := probeTLS(ctx, target, timeout)
+		switch outcome {
+		case probeIdentified:
+			return info, cert
+		case probeUnreachable:
+			return ServiceInfo{}, nil
+		}
+	}
+
+	banner, ok := readBanner(ctx, target, timeout)
+	if !ok {
+		return ServiceInfo{}, nil
+	}
+	if len(banner) > 0 {
+		// The server speaks first, so its greeting is all we will learn
+		info, _ := parseBanner(banner, port)
+		return info, nil
+	}
+
+	for _, probe := range []func(context.Context, string, time.Duration) (ServiceInfo, probeOutcome){probeRedis, probeHTTP} {
+		info, outcome := probe(ctx, target, timeout)
+		switch outcome {
+		case probeIdentified:
+			return info, nil
+		case probeAnswered, probeUnreachable:
+			return ServiceInfo{}, nil
+		}
+	}
+
+	if !tlsPorts[port] {
+		if info, cert, outcome := probeTLS(ctx, target, timeout); outcome == probeIdentified {
+			return info, cert
+		}
+	}
+
+	return ServiceInfo{}, nil
+}
+
+// DetectServices fingerprints every open TCP port in results concurrently,
+// filling in the Service field in place. Ports that speak TLS also get their
+// certificate chain captured in the TLS field.
+func DetectServices(ctx context.Context, results []PortScanResult, timeout time.Duration) {
+	var wg sync.WaitGroup
+
+	for i := range results {
+		if results[i].Protocol != ProtocolTCP || results[i].Status != StatusOpen {
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *PortScanResult) {
+			defer wg.Done()
+			r.Service, r.TLS = DetectService(ctx, r.IP, r.Port, timeout)
+		}(&results[i])
+	}
+
+	wg.Wait()
+}
+
+// readBanner connects and returns whatever the server sends unprompted.
+// ok is false when the connection could not be made.
+func readBanner(ctx context.Context, target string, timeout time.Duration) (banner []byte, ok bool) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, false
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1024)
+	n, _ := conn.Read(buf)
+
+	return buf[:n], true
+}
+
+// parseBanner identifies banner-first protocols from their greeting
+func parseBanner(banner []byte, port int) (ServiceInfo, bool) {
+	// MySQL handshake: 3-byte length, sequence 0, protocol version 10,
+	// then the NUL-terminated server version
+	if len(banner) > 5 && banner[3] == 0x00 && banner[4] == 0x0a {
+		version := banner[5:]
+		if end := bytes.IndexByte(version, 0x00); end > 0 {
+			info := ServiceInfo{Name: "mysql", Product: "MySQL", Version: string(version[:end])}
+			if strings.Contains(info.Version, "MariaDB") {
+				info.Product = "MariaDB"
+			}
+			return info, true
+		}
+	}
+
+	line := firstLine(banner)
+
+	if strings.HasPrefix(line, "SSH-") {
+		info := ServiceInfo{Name: "ssh"}
+		if m := sshBannerRe.FindStringSubmatch(line); m != nil {
+			info.Product = m[1]
+			info.Version = m[2]
+		}
+		return info, true
+	}
+
+	if strings.HasPrefix(line, "220") {
+		info := ServiceInfo{}
+		upper := strings.ToUpper(line)
+		switch {
+		case strings.Contains(upper, "SMTP") || strings.Contains(upper, "MAIL"):
+			info.Name = "smtp"
+		case strings.Contains(upper, "FTP"):
+			info.Name = "ftp"
+		case port == 21:
+			info.Name = "ftp"
+		default:
+			info.Name = "smtp"
+		}
+
+		for _, product := range greetingProducts {
+			if idx := strings.Index(line, product); idx >= 0 {
+				info.Product = product
+				if m := productVersionRe.FindStringSubmatch(line[idx:]); m != nil {
+					info.Version = m[2]
+				}
+				break
+			}
+		}
+		return info, true
+	}
+
+	if strings.HasPrefix(line, "HTTP/") {
+		return parseHTTPResponse(banner), true
+	}
+
+	return ServiceInfo{}, false
+}
+
+// probeTLS attempts a TLS handshake and, if it succeeds, checks for HTTP
+// on top of it. The certificate chain is read from that same handshake.
+func probeTLS(ctx context.Context, target string, timeout time.Duration) (ServiceInfo, *CertificateInfo, probeOutcome) {
+	dialer := net.Dialer{Timeout: timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return ServiceInfo{}, nil, probeUnreachable
+	}
+	defer raw.Close()
+
+	raw.SetDeadline(time.Now().Add(timeout))
+	// Verification is skipped on purpose: expired and self-signed
+	// certificates are exactly what we want to report on
+	conn := tls.Client(raw, &tls.Config{InsecureSkipVerify: true})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return ServiceInfo{}, nil, probeSilent
+	}
+	cert := certificateInfo(conn.ConnectionState())
+
+	if _, err := conn.Write([]byte("HEAD / HTTP/1.0\r\n\r\n")); err == nil {
+		buf := make([]byte, 4096)
+		n, _ := conn.Read(buf)
+		if bytes.HasPrefix(buf[:n], []byte("HTTP/")) {
+			info := parseHTTPResponse(buf[:n])
+			info.Name = "https"
+			return info, cert, probeIdentified
+		}
+	}
+
+	return ServiceInfo{Name: "tls"}, cert, probeIdentified
+}
+
+// probeRedis sends an inline PING and asks for the server version on success.
+// HTTP servers reject the PING with a response we can identify them from.
+func probeRedis(ctx context.Context, target string, timeout time.Duration) (ServiceInfo, probeOutcome) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return ServiceInfo{}, probeUnreachable
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return ServiceInfo{}, probeSilent
+	}
+	buf := make([]byte, 4096)
+	n, _ := conn.Read(buf)
+	reply := buf[:n]
+
+	info := ServiceInfo{Name: "redis", Product: "Redis"}
+	switch {
+	case bytes.HasPrefix(reply, []byte("+PONG")):
+	case bytes.HasPrefix(reply, []byte("-NOAUTH")), bytes.HasPrefix(reply, []byte("-DENIED")):
+		// Redis, but we can't ask it anything else
+		return info, probeIdentified
+	case bytes.HasPrefix(reply, []byte("HTTP/")):
+		return parseHTTPResponse(reply), probeIdentified
+	default:
+		return ServiceInfo{}, replyOutcome(reply)
+	}
+
+	if _, err := conn.Write([]byte("INFO server\r\n")); err != nil {
+		return info, probeIdentified
+	}
+	n, _ = conn.Read(buf)
+	if m := redisVersionRe.FindSubmatch(buf[:n]); m != nil {
+		info.Version = string(m[1])
+	}
+
+	return info, probeIdentified
+}
+
+// probeHTTP sends a HEAD request and reads the Server header
+func probeHTTP(ctx context.Context, target string, timeout time.Duration) (ServiceInfo, probeOutcome) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return ServiceInfo{}, probeUnreachable
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte("HEAD / HTTP/1.0\r\n\r\n")); err != nil {
+		return ServiceInfo{}, probeSilent
+	}
+
+	buf := make([]byte, 4096)
+	n, _ := conn.Read(buf)
+	if !bytes.HasPrefix(buf[:n], []byte("HTTP/")) {
+		return ServiceInfo{}, replyOutcome(buf[:n])
+	}
+
+	return parseHTTPResponse(buf[:n]), probeIdentified
+}
+
+// replyOutcome classifies a reply no plaintext probe recognised. Silence and
+// TLS alerts leave the TLS probe worth trying; anything else is final.
+func replyOutcome(reply []byte) probeOutcome {
+	if len(reply) == 0 || reply[0] == tlsAlertRecord {
+		return probeSilent
+	}
+	return probeAnswered
+}
+
+// parseHTTPResponse extracts product and version from the Server header
+func parseHTTPResponse(response []byte) ServiceInfo {
+	info := ServiceInfo{Name: "http"}
+
+	for _, line := range strings.Split(string(response), "\r\n") {
+		if len(line) < 7 || !strings.EqualFold(line[:7], "server:") {
+			continue
+		}
+
+		server := strings.TrimSpace(line[7:])
+		if m := productVersionRe.FindStringSubmatch(server); m != nil {
+			info.Product = m[1]
+			info.Version = m[2]
+		} else {
+			info.Product = server
+		}
+		break
+	}
+
+	return info
+}
+
+// firstLine returns the banner up to the first line break
+func firstLine(banner []byte) string {
+	line := string(banner)
+	if idx := strings.IndexAny(line, "\r\n"); idx >= 0 {
+		line = line[:idx]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseBanner(t *testing.T) {
+	// MySQL handshake: 3-byte length, sequence 0, protocol version 10
+	mysql := func(version string) string {
+		return "\x4a\x00\x00\x00\x0a" + version + "\x00\x08\x00\x00\x00"
+	}
+
+	tests := []struct {
+		name   string
+		banner string
+		port   int
+		want   ServiceInfo
+		ok     bool
+	}{
+		{
+			name:   "openssh",
+			banner: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1\r\n",
+			port:   22,
+			want:   ServiceInfo{Name: "ssh", Product: "OpenSSH", Version: "8.9p1"},
+			ok:     true,
+		},
+		{
+			name:   "dropbear",
+			banner: "SSH-2.0-dropbear_2020.81\r\n",
+			port:   2222,
+			want:   ServiceInfo{Name: "ssh", Product: "dropbear", Version: "2020.81"},
+			ok:     true,
+		},
+		{
+			name:   "vsftpd",
+			banner: "220 (vsFTPd 3.0.3)\r\n",
+			port:   21,
+			want:   ServiceInfo{Name: "ftp", Product: "vsFTPd", Version: "3.0.3"},
+			ok:     true,
+		},
+		{
+			name:   "proftpd",
+			banner: "220 ProFTPD 1.3.5 Server ready.\r\n",
+			port:   2121,
+			want:   ServiceInfo{Name: "ftp", Product: "ProFTPD", Version: "1.3.5"},
+			ok:     true,
+		},
+		{
+			name:   "postfix without version",
+			banner: "220 mail.example.com ESMTP Postfix (Ubuntu)\r\n",
+			port:   25,
+			want:   ServiceInfo{Name: "smtp", Product: "Postfix"},
+			ok:     true,
+		},
+		{
+			name:   "exim",
+			banner: "220 mx.example.com ESMTP Exim 4.94.2 Mon, 01 Jan 2024 00:00:00 +0000\r\n",
+			port:   25,
+			want:   ServiceInfo{Name: "smtp", Product: "Exim", Version: "4.94.2"},
+			ok:     true,
+		},
+		{
+			name:   "anonymous greeting on the ftp port",
+			banner: "220 Welcome\r\n",
+			port:   21,
+			want:   ServiceInfo{Name: "ftp"},
+			ok:     true,
+		},
+		{
+			name:   "anonymous greeting elsewhere",
+			banner: "220 Welcome\r\n",
+			port:   587,
+			want:   ServiceInfo{Name: "smtp"},
+			ok:     true,
+		},
+		{
+			name:   "http",
+			banner: "HTTP/1.1 400 Bad Request\r\nServer: nginx/1.18.0 (Ubuntu)\r\n\r\n",
+			port:   8080,
+			want:   ServiceInfo{Name: "http", Product: "nginx", Version: "1.18.0"},
+			ok:     true,
+		},
+		{
+			name:   "http server without version",
+			banner: "HTTP/1.0 404 Not Found\r\nserver: gws\r\n\r\n",
+			port:   80,
+			want:   ServiceInfo{Name: "http", Product: "gws"},
+			ok:     true,
+		},
+		{
+			name:   "mysql",
+			banner: mysql("8.0.33"),
+			port:   3306,
+			want:   ServiceInfo{Name: "mysql", Product: "MySQL", Version: "8.0.33"},
+			ok:     true,
+		},
+		{
+			name:   "mariadb",
+			banner: mysql("5.5.5-10.6.12-MariaDB"),
+			port:   3306,
+			want:   ServiceInfo{Name: "mysql", Product: "MariaDB", Version: "5.5.5-10.6.12-MariaDB"},
+			ok:     true,
+		},
+		{
+			name:   "truncated mysql handshake",
+			banner: "\x4a\x00\x00\x00\x0a8.0",
+			port:   3306,
+		},
+		{
+			name:   "unknown",
+			banner: "hello\r\n",
+			port:   1234,
+		},
+		{
+			name: "empty",
+			port: 1234,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseBanner([]byte(tt.banner), tt.port)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: parseBanner() = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDetectServiceStopsAtFirstAnswer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	// The PING probe draws a 400 from the HTTP server, which settles it
+	// before the HEAD and TLS probes are sent
+	start := time.Now()
+	info, cert := DetectService(context.Background(), host, port, 5*time.Second)
+	if info.Name != "http" || cert != nil {
+		t.Errorf("DetectService() = %+v, %v, want http without a certificate", info, cert)
+	}
+	if elapsed := time.Since(start); elapsed > 2*serviceProbeTimeout {
+		t.Errorf("DetectService() took %v, want at most %v", elapsed, 2*serviceProbeTimeout)
+	}
+}
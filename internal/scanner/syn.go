@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+// ErrSYNUnavailable is returned when raw sockets can't be opened, either
+// because the platform isn't supported or the process lacks CAP_NET_RAW
+var ErrSYNUnavailable = errors.New("SYN scanning unavailable")
+
+// synProbeAttempts is the minimum number of rounds of SYNs sent. The kernel
+// retransmits the SYN of a connect scan on its own; raw probes get no such
+// help, so one retry is always made for ports that stay silent.
+const synProbeAttempts = 2
+
+var (
+	synCheckOnce sync.Once
+	synAvailable bool
+)
+
+// SYNAvailable reports whether the SYN engine can be used. The check opens
+// a raw socket once and caches the answer.
+func SYNAvailable() bool {
+	synCheckOnce.Do(func() {
+		err := checkRawSocket()
+		synAvailable = err == nil
+		if err != nil {
+			log.Printf("SYN scanning unavailable, using connect scans: %v", err)
+		}
+	})
+	return synAvailable
+}
+
+// tcpChecksum computes the TCP checksum of segment over the IPv4 or IPv6
+// pseudo header for src and dst
+func tcpChecksum(src, dst []byte, segment []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+
+	add(src)
+	add(dst)
+	sum += 6 // protocol number of TCP
+	sum += uint32(len(segment))
+	add(segment)
+
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,229 @@
+//go:build linux
+
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+
+	// synReadSlice bounds each blocking read so the deadline is honoured
+	synReadSlice = 100 * time.Millisecond
+)
+
+func checkRawSocket() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSYNUnavailable, err)
+	}
+	syscall.Close(fd)
+	return nil
+}
+
+// synScan sends a SYN to every port and classifies the replies: SYN/ACK is
+// open, RST is closed, silence is filtered. The kernel answers the SYN/ACK
+// with a reset because no socket owns the source port, so the handshake is
+// never completed. Unanswered ports are probed again for up to attempts
+// rounds, which share the timeout between them.
+func synScan(ctx context.Context, ip string, ports []int, timeout time.Duration, attempts int) ([]PortScanResult, error) {
+	dst, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	dst = dst.Unmap()
+
+	src, err := sourceAddr(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	family := syscall.AF_INET
+	if dst.Is6() {
+		family = syscall.AF_INET6
+	}
+	fd, err := syscall.Socket(family, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSYNUnavailable, err)
+	}
+	defer syscall.Close(fd)
+
+	tv := syscall.NsecToTimeval(synReadSlice.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+
+	var sa syscall.Sockaddr
+	if dst.Is4() {
+		sa = &syscall.SockaddrInet4{Addr: dst.As4()}
+	} else {
+		sa = &syscall.SockaddrInet6{Addr: dst.As16()}
+	}
+
+	// A random high source port and sequence number identify our replies
+	srcPort := uint16(32768 + rand.Intn(28232))
+	seq := rand.Uint32()
+
+	results := make([]PortScanResult, len(ports))
+	index := make(map[uint16]int, len(ports))
+	sentAt := make([]time.Time, len(ports))
+	for i, port := range ports {
+		results[i] = PortScanResult{
+			IP:       ip,
+			Port:     port,
+			Protocol: ProtocolTCP,
+			Status:   StatusFiltered,
+			Engine:   EngineSYN,
+		}
+		index[uint16(port)] = i
+	}
+
+	answered := 0
+	buf := make([]byte, 1500)
+
+	for attempt := 0; attempt < attempts && answered < len(ports); attempt++ {
+		for i, port := range ports {
+			if results[i].Status != StatusFiltered {
+				continue
+			}
+			segment := buildSYN(src, dst, srcPort, uint16(port), seq)
+			if err := sendRaw(fd, segment, sa); err != nil {
+				return nil, err
+			}
+			sentAt[i] = time.Now()
+		}
+
+		// Split the timeout across attempts so a scan takes about as long
+		// as a connect scan of the same ports
+		deadline := time.Now().Add(timeout / time.Duration(attempts))
+		for answered < len(ports) && time.Now().Before(deadline) {
+			if err := ctx.Err(); err != nil {
+				return answeredOnly(results), err
+			}
+
+			n, from, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+					continue
+				}
+				return nil, err
+			}
+
+			if !sockaddrMatches(from, dst) {
+				continue
+			}
+
+			segment := buf[:n]
+			if dst.Is4() {
+				// IPv4 raw sockets deliver the IP header, IPv6 ones don't
+				if n < 20 {
+					continue
+				}
+				segment = buf[int(buf[0]&0x0f)*4 : n]
+			}
+			if len(segment) < 20 {
+				continue
+			}
+
+			remotePort := binary.BigEndian.Uint16(segment[0:2])
+			localPort := binary.BigEndian.Uint16(segment[2:4])
+			ack := binary.BigEndian.Uint32(segment[8:12])
+			flags := segment[13]
+
+			i, ok := index[remotePort]
+			if !ok || localPort != srcPort || ack != seq+1 || results[i].Status != StatusFiltered {
+				continue
+			}
+
+			switch {
+			case flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK:
+				results[i].Status = StatusOpen
+				results[i].ResponseTimeMs = int(time.Since(sentAt[i]).Milliseconds())
+			case flags&tcpFlagRST != 0:
+				results[i].Status = StatusClosed
+			default:
+				continue
+			}
+			answered++
+		}
+	}
+
+	return results, nil
+}
+
+// answeredOnly drops ports that haven't replied yet; when a scan is cut
+// short their silence doesn't mean they are filtered
+func answeredOnly(results []PortScanResult) []PortScanResult {
+	answered := results[:0]
+	for _, r := range results {
+		if r.Status != StatusFiltered {
+			answered = append(answered, r)
+		}
+	}
+	return answered
+}
+
+// sourceAddr finds the local address the kernel would route dst from
+func sourceAddr(dst netip.Addr) (netip.Addr, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+
+	local, ok := netip.AddrFromSlice(conn.LocalAddr().(*net.UDPAddr).IP)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("no source address for %s", dst)
+	}
+	return local.Unmap(), nil
+}
+
+// buildSYN builds a TCP SYN segment with an MSS option
+func buildSYN(src, dst netip.Addr, srcPort, dstPort uint16, seq uint32) []byte {
+	segment := make([]byte, 24)
+	binary.BigEndian.PutUint16(segment[0:2], srcPort)
+	binary.BigEndian.PutUint16(segment[2:4], dstPort)
+	binary.BigEndian.PutUint32(segment[4:8], seq)
+	segment[12] = 6 << 4 // data offset in 32-bit words
+	segment[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(segment[14:16], 1024) // window
+	copy(segment[20:], []byte{2, 4, 0x05, 0xb4})     // MSS 1460
+
+	binary.BigEndian.PutUint16(segment[16:18], tcpChecksum(src.AsSlice(), dst.AsSlice(), segment))
+	return segment
+}
+
+// sendRaw writes a segment, backing off briefly when the send buffer is full
+func sendRaw(fd int, segment []byte, sa syscall.Sockaddr) error {
+	for retries := 0; ; retries++ {
+		err := syscall.Sendto(fd, segment, 0, sa)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.ENOBUFS) && !errors.Is(err, syscall.EAGAIN) || retries >= 10 {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func sockaddrMatches(sa syscall.Sockaddr, addr netip.Addr) bool {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return addr.Is4() && sa.Addr == addr.As4()
+	case *syscall.SockaddrInet6:
+		return addr.Is6() && sa.Addr == addr.As16()
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"context"
+	"time"
+)
+
+func checkRawSocket() error {
+	return ErrSYNUnavailable
+}
+
+func synScan(ctx context.Context, ip string, ports []int, timeout time.Duration, attempts int) ([]PortScanResult, error) {
+	return nil, ErrSYNUnavailable
+}
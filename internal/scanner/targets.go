@@ -0,0 +1,299 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MinIPv6PrefixLen is the shortest IPv6 prefix accepted as a target. A /120
+// is 256 addresses; anything wider is too sparse to walk address by address
+// and should be given as an explicit host list instead.
+const MinIPv6PrefixLen = 120
+
+// MaxTargetAddresses is the largest target (a /16) accepted without an
+// explicit override
+const MaxTargetAddresses = 65536
+
+// ErrTargetTooLarge is returned by CheckSize for targets over MaxTargetAddresses
+var ErrTargetTooLarge = errors.New("target too large")
+
+// hostnameRe matches RFC 1123 host names. The last label must contain a
+// letter so that malformed IPs like 300.1.1.1 aren't mistaken for names.
+var hostnameRe = regexp.MustCompile(`^(?i)([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9-]*[a-z][a-z0-9-]*$`)
+
+// addrRange is an inclusive range of addresses within one address family
+type addrRange struct {
+	start netip.Addr
+	end   netip.Addr
+}
+
+// Target is a parsed target definition. It stores address ranges rather than
+// addresses so that memory use doesn't grow with the size of the target.
+// Host names are kept unresolved until Resolve is called at scan time.
+type Target struct {
+	ranges    []addrRange
+	hostnames []string
+}
+
+// Resolution records what a host name in a target resolved to
+type Resolution struct {
+	Hostname  string
+	Addresses []string
+	Err       error
+}
+
+// ParseTarget parses a target definition.
+//
+// A target is a single IPv4/IPv6 address, a CIDR range, a host name, or a
+// list of any of these separated by commas or whitespace (e.g. an IPv6
+// hitlist). IPv6 ranges must be MinIPv6PrefixLen or narrower. Overlapping
+// entries are merged so each address is only scanned once.
+func ParseTarget(target string) (*Target, error) {
+	entries := strings.FieldsFunc(target, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("empty target")
+	}
+
+	t := &Target{}
+	seenHosts := make(map[string]bool)
+	ranges := make([]addrRange, 0, len(entries))
+
+	for _, entry := range entries {
+		if hostname, ok := parseHostname(entry); ok {
+			if !seenHosts[hostname] {
+				seenHosts[hostname] = true
+				t.hostnames = append(t.hostnames, hostname)
+			}
+			continue
+		}
+
+		r, err := parseTargetEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+
+	t.ranges = mergeRanges(ranges)
+	return t, nil
+}
+
+// parseHostname returns the normalized host name if entry is one
+func parseHostname(entry string) (string, bool) {
+	hostname := strings.ToLower(strings.TrimSuffix(entry, "."))
+	if len(hostname) == 0 || len(hostname) > 253 || !hostnameRe.MatchString(hostname) {
+		return "", false
+	}
+	return hostname, true
+}
+
+// parseTargetEntry converts a single address or CIDR range into an address range
+func parseTargetEntry(entry string) (addrRange, error) {
+	// Check if it's a single IP or CIDR
+	if !strings.Contains(entry, "/") {
+		// Single IP address
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return addrRange{}, fmt.Errorf("invalid IP address: %s", entry)
+		}
+		addr = addr.Unmap().WithZone("")
+		return addrRange{start: addr, end: addr}, nil
+	}
+
+	// Parse CIDR
+	prefix, err := netip.ParsePrefix(entry)
+	if err != nil {
+		return addrRange{}, fmt.Errorf("invalid CIDR notation: %s", entry)
+	}
+	prefix = prefix.Masked()
+
+	if prefix.Addr().Is6() && prefix.Bits() < MinIPv6PrefixLen {
+		return addrRange{}, fmt.Errorf("IPv6 range %s is too large: use a /%d or smaller prefix, or list hosts explicitly", entry, MinIPv6PrefixLen)
+	}
+
+	r := addrRange{start: prefix.Addr(), end: lastAddr(prefix)}
+
+	// Remove network and broadcast addresses for IPv4 networks larger than /31.
+	// IPv6 has no broadcast address, so every address is a potential host.
+	if prefix.Addr().Is4() && prefix.Bits() < 31 {
+		r.start = r.start.Next()
+		r.end = r.end.Prev()
+	}
+
+	return r, nil
+}
+
+// lastAddr returns the highest address in prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// mergeRanges sorts ranges and joins any that overlap or touch
+func mergeRanges(ranges []addrRange) []addrRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Less(ranges[j].start)
+	})
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			next := last.end.Next()
+			if last.end.BitLen() == r.start.BitLen() && (!next.IsValid() || !next.Less(r.start)) {
+				if last.end.Less(r.end) {
+					last.end = r.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// Hostnames returns the host names in the target
+func (t *Target) Hostnames() []string {
+	return t.hostnames
+}
+
+// Resolve looks up the A and AAAA records of every host name in the target
+// and adds the addresses to it. It is meant to be called once per scan so
+// that targets follow DNS changes.
+func (t *Target) Resolve(ctx context.Context) []Resolution {
+	resolutions := make([]Resolution, 0, len(t.hostnames))
+	ranges := t.ranges
+
+	for _, hostname := range t.hostnames {
+		res := Resolution{Hostname: hostname, Addresses: []string{}}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
+		if err != nil {
+			res.Err = err
+			resolutions = append(resolutions, res)
+			continue
+		}
+
+		for _, ipAddr := range addrs {
+			addr, ok := netip.AddrFromSlice(ipAddr.IP)
+			if !ok {
+				continue
+			}
+			addr = addr.Unmap()
+			res.Addresses = append(res.Addresses, addr.String())
+			ranges = append(ranges, addrRange{start: addr, end: addr})
+		}
+		sort.Strings(res.Addresses)
+
+		resolutions = append(resolutions, res)
+	}
+
+	t.ranges = mergeRanges(ranges)
+	return resolutions
+}
+
+// ReverseLookup returns the PTR names of an IP address, without the trailing dot
+func ReverseLookup(ctx context.Context, ip string) ([]string, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	hostnames := make([]string, 0, len(names))
+	for _, name := range names {
+		hostnames = append(hostnames, strings.ToLower(strings.TrimSuffix(name, ".")))
+	}
+	return hostnames, nil
+}
+
+// Count returns the number of addresses in the target without expanding it.
+// Host names only contribute once they have been resolved.
+// Counts too large for a uint64 are capped at math.MaxUint64.
+func (t *Target) Count() uint64 {
+	var total uint64
+	for _, r := range t.ranges {
+		size := rangeSize(r)
+		if total+size < total {
+			return math.MaxUint64
+		}
+		total += size
+	}
+	return total
+}
+
+func rangeSize(r addrRange) uint64 {
+	if r.start.Is4() {
+		start, end := r.start.As4(), r.end.As4()
+		return uint64(binary.BigEndian.Uint32(end[:])-binary.BigEndian.Uint32(start[:])) + 1
+	}
+
+	start, end := r.start.As16(), r.end.As16()
+	if binary.BigEndian.Uint64(start[:8]) != binary.BigEndian.Uint64(end[:8]) {
+		return math.MaxUint64
+	}
+	diff := binary.BigEndian.Uint64(end[8:]) - binary.BigEndian.Uint64(start[8:])
+	if diff == math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return diff + 1
+}
+
+// CheckSize returns ErrTargetTooLarge if the target covers more than
+// MaxTargetAddresses, unless allowLarge is set
+func (t *Target) CheckSize(allowLarge bool) error {
+	if count := t.Count(); count > MaxTargetAddresses && !allowLarge {
+		return fmt.Errorf("%w: %d addresses exceeds the limit of %d", ErrTargetTooLarge, count, MaxTargetAddresses)
+	}
+	return nil
+}
+
+// Addresses returns an iterator over every address in the target
+func (t *Target) Addresses() *AddressIterator {
+	return &AddressIterator{ranges: t.ranges}
+}
+
+// AddressIterator lazily walks the addresses of a Target
+type AddressIterator struct {
+	ranges  []addrRange
+	current netip.Addr
+	started bool
+}
+
+// Next returns the next address, or false once the target is exhausted
+func (it *AddressIterator) Next() (string, bool) {
+	for len(it.ranges) > 0 {
+		r := it.ranges[0]
+
+		if !it.started {
+			it.current = r.start
+			it.started = true
+			return it.current.String(), true
+		}
+
+		if it.current != r.end {
+			it.current = it.current.Next()
+			return it.current.String(), true
+		}
+
+		it.ranges = it.ranges[1:]
+		it.started = false
+	}
+
+	return "", false
+}
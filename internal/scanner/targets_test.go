@@ -0,0 +1,215 @@
+package scanner
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// addresses drains the iterator of a target
+func addresses(target *Target) []string {
+	all := []string{}
+	it := target.Addresses()
+	for ip, ok := it.Next(); ok; ip, ok = it.Next() {
+		all = append(all, ip)
+	}
+	return all
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target    string
+		count     uint64
+		addresses []string
+		hostnames []string
+	}{
+		{
+			target:    "192.168.1.10",
+			count:     1,
+			addresses: []string{"192.168.1.10"},
+		},
+		{
+			// Network and broadcast addresses are left out
+			target:    "10.0.0.0/30",
+			count:     2,
+			addresses: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			target:    "10.0.0.0/31",
+			count:     2,
+			addresses: []string{"10.0.0.0", "10.0.0.1"},
+		},
+		{
+			target:    "10.0.0.3, 10.0.0.1;10.0.0.2",
+			count:     3,
+			addresses: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		},
+		{
+			target:    "10.0.0.0/30 10.0.0.2",
+			count:     2,
+			addresses: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			// IPv6 has no broadcast address
+			target:    "2001:db8::/126",
+			count:     4,
+			addresses: []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"},
+		},
+		{
+			target:    "::ffff:10.0.0.1",
+			count:     1,
+			addresses: []string{"10.0.0.1"},
+		},
+		{
+			target:    "Example.COM.",
+			count:     0,
+			addresses: []string{},
+			hostnames: []string{"example.com"},
+		},
+		{
+			target:    "10.0.0.2 host.example\n10.0.0.1 host.example",
+			count:     2,
+			addresses: []string{"10.0.0.1", "10.0.0.2"},
+			hostnames: []string{"host.example"},
+		},
+	}
+
+	for _, tt := range tests {
+		target, err := ParseTarget(tt.target)
+		if err != nil {
+			t.Errorf("ParseTarget(%q) error: %v", tt.target, err)
+			continue
+		}
+		if got := target.Count(); got != tt.count {
+			t.Errorf("ParseTarget(%q).Count() = %d, want %d", tt.target, got, tt.count)
+		}
+		if got := addresses(target); !reflect.DeepEqual(got, tt.addresses) {
+			t.Errorf("ParseTarget(%q) addresses = %v, want %v", tt.target, got, tt.addresses)
+		}
+		if got := target.Hostnames(); !reflect.DeepEqual(got, tt.hostnames) {
+			t.Errorf("ParseTarget(%q).Hostnames() = %v, want %v", tt.target, got, tt.hostnames)
+		}
+	}
+}
+
+func TestParseTargetInvalid(t *testing.T) {
+	for _, target := range []string{
+		"",
+		" , ",
+		"300.1.1.1",
+		"10.0.0.0/33",
+		"10.0.0.1/abc",
+		"2001:db8::/64",
+		"host_name.example",
+	} {
+		if _, err := ParseTarget(target); err == nil {
+			t.Errorf("ParseTarget(%q) succeeded, want error", target)
+		}
+	}
+}
+
+func TestTargetCount(t *testing.T) {
+	tests := []struct {
+		target string
+		want   uint64
+	}{
+		{"10.0.0.0/8", 16777214},
+		{"10.0.0.0/16", 65534},
+		{"10.0.0.0/24 10.0.1.0/24", 508},
+		{"2001:db8::/120", 256},
+		{"2001:db8::/120 10.0.0.0/24", 510},
+	}
+
+	for _, tt := range tests {
+		target, err := ParseTarget(tt.target)
+		if err != nil {
+			t.Errorf("ParseTarget(%q) error: %v", tt.target, err)
+			continue
+		}
+		if got := target.Count(); got != tt.want {
+			t.Errorf("ParseTarget(%q).Count() = %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestMergeRanges(t *testing.T) {
+	r := func(start, end string) addrRange {
+		return addrRange{start: netip.MustParseAddr(start), end: netip.MustParseAddr(end)}
+	}
+
+	tests := []struct {
+		name   string
+		ranges []addrRange
+		want   []addrRange
+	}{
+		{
+			name:   "touching",
+			ranges: []addrRange{r("10.0.0.1", "10.0.0.2"), r("10.0.0.3", "10.0.0.4")},
+			want:   []addrRange{r("10.0.0.1", "10.0.0.4")},
+		},
+		{
+			name:   "overlapping and unsorted",
+			ranges: []addrRange{r("10.0.0.5", "10.0.0.9"), r("10.0.0.1", "10.0.0.6")},
+			want:   []addrRange{r("10.0.0.1", "10.0.0.9")},
+		},
+		{
+			name:   "contained",
+			ranges: []addrRange{r("10.0.0.1", "10.0.0.10"), r("10.0.0.3", "10.0.0.4")},
+			want:   []addrRange{r("10.0.0.1", "10.0.0.10")},
+		},
+		{
+			name:   "disjoint",
+			ranges: []addrRange{r("10.0.0.4", "10.0.0.5"), r("10.0.0.1", "10.0.0.2")},
+			want:   []addrRange{r("10.0.0.1", "10.0.0.2"), r("10.0.0.4", "10.0.0.5")},
+		},
+		{
+			name:   "address families kept apart",
+			ranges: []addrRange{r("::1", "::1"), r("10.0.0.1", "10.0.0.1")},
+			want:   []addrRange{r("10.0.0.1", "10.0.0.1"), r("::1", "::1")},
+		},
+		{
+			name:   "last address",
+			ranges: []addrRange{r("255.255.255.254", "255.255.255.255"), r("255.255.255.255", "255.255.255.255")},
+			want:   []addrRange{r("255.255.255.254", "255.255.255.255")},
+		},
+		{
+			name:   "empty",
+			ranges: []addrRange{},
+			want:   []addrRange{},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := mergeRanges(tt.ranges); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeRanges() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddressIterator(t *testing.T) {
+	target, err := ParseTarget("10.0.0.255 10.0.1.0 2001:db8::ff")
+	if err != nil {
+		t.Fatalf("ParseTarget error: %v", err)
+	}
+
+	want := []string{"10.0.0.255", "10.0.1.0", "2001:db8::ff"}
+	it := target.Addresses()
+	for _, w := range want {
+		got, ok := it.Next()
+		if !ok || got != w {
+			t.Fatalf("Next() = %q, %v, want %q, true", got, ok, w)
+		}
+	}
+
+	// An exhausted iterator stays exhausted
+	for i := 0; i < 2; i++ {
+		if got, ok := it.Next(); ok {
+			t.Fatalf("Next() after the last address = %q, want none", got)
+		}
+	}
+
+	// Each iterator starts from the beginning
+	if got := addresses(target); !reflect.DeepEqual(got, want) {
+		t.Errorf("second iteration = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+)
+
+// CertificateInfo describes the certificate chain presented on a TLS port
+type CertificateInfo struct {
+	Subject           string
+	Issuer            string
+	SANs              []string
+	NotBefore         time.Time
+	NotAfter          time.Time
+	KeyType           string
+	KeySize           int
+	SelfSigned        bool
+	TLSVersion        string
+	CipherSuite       string
+	FingerprintSHA256 string
+	Chain             []ChainCertificate
+}
+
+// ChainCertificate is a summary of one certificate in the presented chain
+type ChainCertificate struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	NotAfter          time.Time `json:"not_after"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+}
+
+// certificateInfo summarises the certificate chain presented in a completed
+// TLS handshake, or returns nil if the server sent none
+func certificateInfo(state tls.ConnectionState) *CertificateInfo {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+	info := &CertificateInfo{
+		Subject:           leaf.Subject.String(),
+		Issuer:            leaf.Issuer.String(),
+		NotBefore:         leaf.NotBefore,
+		NotAfter:          leaf.NotAfter,
+		SelfSigned:        isSelfSigned(leaf),
+		TLSVersion:        tls.VersionName(state.Version),
+		CipherSuite:       tls.CipherSuiteName(state.CipherSuite),
+		FingerprintSHA256: fingerprint(leaf),
+	}
+
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	info.KeyType, info.KeySize = publicKeyInfo(leaf)
+
+	for _, cert := range state.PeerCertificates {
+		info.Chain = append(info.Chain, ChainCertificate{
+			Subject:           cert.Subject.String(),
+			Issuer:            cert.Issuer.String(),
+			NotAfter:          cert.NotAfter,
+			FingerprintSHA256: fingerprint(cert),
+		})
+	}
+
+	return info
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	if cert.Subject.String() != cert.Issuer.String() {
+		return false
+	}
+	return cert.CheckSignatureFrom(cert) == nil
+}
+
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	default:
+		return cert.PublicKeyAlgorithm.String(), 0
+	}
+}
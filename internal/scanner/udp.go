@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+var CommonUDPPorts = []int{
+	53,    // DNS
+	123,   // NTP
+	161,   // SNMP
+	500,   // IKE
+	1900,  // SSDP
+	11211, // Memcached
+}
+
+// udpProbeAttempts is the minimum number of probes sent before a silent port
+// is reported as open|filtered (UDP gives no delivery guarantee)
+const udpProbeAttempts = 2
+
+// udpProbes holds protocol-specific payloads that elicit a reply from the
+// service normally listening on that port. Ports without an entry get an
+// empty datagram.
+var udpProbes = map[int][]byte{
+	// DNS: standard query for the root NS records
+	53: {
+		0x13, 0x37, // transaction ID
+		0x01, 0x00, // flags: recursion desired
+		0x00, 0x01, // questions
+		0x00, 0x00, // answer RRs
+		0x00, 0x00, // authority RRs
+		0x00, 0x00, // additional RRs
+		0x00,       // root name
+		0x00, 0x02, // type NS
+		0x00, 0x01, // class IN
+	},
+
+	// NTP: version 3 client request
+	123: append([]byte{0x1b}, make([]byte, 47)...),
+
+	// SNMP: v1 GetRequest for sysDescr.0 with community "public"
+	161: {
+		0x30, 0x29, // SEQUENCE
+		0x02, 0x01, 0x00, // version: 1
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c', // community
+		0xa0, 0x1c, // GetRequest PDU
+		0x02, 0x04, 0x00, 0x00, 0x00, 0x01, // request ID
+		0x02, 0x01, 0x00, // error status
+		0x02, 0x01, 0x00, // error index
+		0x30, 0x0e, // varbind list
+		0x30, 0x0c, // varbind
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, // OID 1.3.6.1.2.1.1.1.0
+		0x05, 0x00, // NULL
+	},
+
+	// IKE: ISAKMP main mode with a single 3DES/SHA1/MODP1024/PSK proposal
+	500: {
+		0x13, 0x37, 0x13, 0x37, 0x13, 0x37, 0x13, 0x37, // initiator cookie
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // responder cookie
+		0x01,                   // next payload: SA
+		0x10,                   // version 1.0
+		0x02,                   // exchange type: identity protection
+		0x00,                   // flags
+		0x00, 0x00, 0x00, 0x00, // message ID
+		0x00, 0x00, 0x00, 0x50, // length
+		// SA payload
+		0x00, 0x00, 0x00, 0x34,
+		0x00, 0x00, 0x00, 0x01, // DOI: IPsec
+		0x00, 0x00, 0x00, 0x01, // situation: identity only
+		// proposal payload
+		0x00, 0x00, 0x00, 0x28,
+		0x01, 0x01, 0x00, 0x01, // proposal 1, ISAKMP, no SPI, 1 transform
+		// transform payload
+		0x00, 0x00, 0x00, 0x20,
+		0x01, 0x01, 0x00, 0x00, // transform 1, KEY_IKE
+		0x80, 0x01, 0x00, 0x05, // encryption: 3DES
+		0x80, 0x02, 0x00, 0x02, // hash: SHA1
+		0x80, 0x03, 0x00, 0x01, // auth: pre-shared key
+		0x80, 0x04, 0x00, 0x02, // group: MODP1024
+		0x80, 0x0b, 0x00, 0x01, // life type: seconds
+		0x80, 0x0c, 0x70, 0x80, // life duration: 28800
+	},
+
+	// SSDP: discovery request
+	1900: []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: ssdp:all\r\n\r\n"),
+
+	// Memcached: UDP frame header followed by a version command
+	11211: append([]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, []byte("version\r\n")...),
+}
+
+// ScanUDPPort sends a single probe to a UDP port on an IP address.
+//
+// A reply of any kind means the port is open. An ICMP port-unreachable, which
+// the kernel surfaces as ECONNREFUSED on a connected UDP socket, means it is
+// closed. Silence is reported as open|filtered since a firewall dropping the
+// probe is indistinguishable from a service that ignored it; UDPScanner
+// retries those.
+func ScanUDPPort(ctx context.Context, ip string, port int, timeout time.Duration) PortScanResult {
+	result := PortScanResult{
+		IP:       ip,
+		Port:     port,
+		Protocol: ProtocolUDP,
+		Status:   StatusOpenFiltered,
+		Engine:   EngineUDP,
+	}
+
+	target := net.JoinHostPort(ip, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", target)
+	if err != nil {
+		result.Status = StatusUnreachable
+		return result
+	}
+	defer conn.Close()
+
+	start := time.Now()
+
+	if _, err := conn.Write(udpProbes[port]); err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			result.Status = StatusClosed
+		}
+		return result
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	_, err = conn.Read(make([]byte, 1500))
+	if err == nil {
+		result.Status = StatusOpen
+		result.ResponseTimeMs = int(time.Since(start).Milliseconds())
+		result.Service.Name = udpServiceNames[port]
+		return result
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		result.Status = StatusClosed
+	}
+	return result
+}
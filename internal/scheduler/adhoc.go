@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"sync"
+
+	"github.com/lib/pq"
+
+	"ip-scanner/internal/models"
+	"ip-scanner/internal/scanner"
+)
+
+const (
+	// adhocWorkers is how many IPs of an ad-hoc scan are scanned at once
+	adhocWorkers = 20
+
+	// maxAdhocJobs is how many ad-hoc scans may run at once, whether in the
+	// background or while the client waits
+	maxAdhocJobs = 2
+
+	// MaxAdhocProbes is the most port probes a single ad-hoc scan may send
+	MaxAdhocProbes = 65536
+)
+
+var (
+	// ErrTooManyAdhocScans is returned when the ad-hoc scan slots are all taken
+	ErrTooManyAdhocScans = errors.New("too many ad-hoc scans running")
+
+	// ErrAdhocUnresolved is returned when a target made up of host names has
+	// none that resolve
+	ErrAdhocUnresolved = errors.New("no host name of the target resolved")
+
+	// ErrAdhocTooLarge is returned when a target's host names resolve to more
+	// addresses than MaxAdhocProbes allows
+	ErrAdhocTooLarge = fmt.Errorf("ad-hoc scan needs more than %d probes", MaxAdhocProbes)
+)
+
+// AdhocScan is an on-demand scan of an IP, range or host name that need not
+// be a saved target
+type AdhocScan struct {
+	Target   *scanner.Target
+	Ports    []int
+	UDPPorts []int
+	Engine   string
+}
+
+// RunAdhoc scans scan.Target while the caller waits, taking one of the
+// ad-hoc scan slots; ErrTooManyAdhocScans is returned if none is free
+func (s *Scheduler) RunAdhoc(ctx context.Context, scan AdhocScan) ([]models.AdhocResult, error) {
+	select {
+	case s.adhocSlots <- struct{}{}:
+	default:
+		return nil, ErrTooManyAdhocScans
+	}
+	defer func() { <-s.adhocSlots }()
+
+	return s.runAdhoc(ctx, scan)
+}
+
+// runAdhoc scans scan.Target and returns the ports that are open or may be,
+// ordered by address, protocol and port; closed and unreachable ports are
+// left out. Nothing is written to port_state, so ad-hoc scans don't take
+// part in change detection or notifications. If ctx is cancelled the
+// results gathered so far are returned along with ctx.Err().
+func (s *Scheduler) runAdhoc(ctx context.Context, scan AdhocScan) ([]models.AdhocResult, error) {
+	if len(scan.Target.Hostnames()) > 0 {
+		for _, resolution := range scan.Target.Resolve(ctx) {
+			if resolution.Err != nil {
+				log.Printf("Ad-hoc scan: failed to resolve %s: %v", resolution.Hostname, resolution.Err)
+			}
+		}
+
+		addresses := scan.Target.Count()
+		if addresses == 0 {
+			return []models.AdhocResult{}, ErrAdhocUnresolved
+		}
+		if addresses > MaxAdhocProbes/uint64(len(scan.Ports)+len(scan.UDPPorts)) {
+			return []models.AdhocResult{}, ErrAdhocTooLarge
+		}
+	}
+
+	tcpScanner := s.engineFor(scan.Engine)
+	udpScanner, _ := scanner.Lookup(scanner.EngineUDP)
+
+	ipChan := make(chan string, adhocWorkers)
+	go func() {
+		defer close(ipChan)
+		addresses := scan.Target.Addresses()
+		for ip, ok := addresses.Next(); ok; ip, ok = addresses.Next() {
+			select {
+			case ipChan <- ip:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = []models.AdhocResult{}
+	)
+
+	for i := 0; i < adhocWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range ipChan {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				portResults, err := tcpScanner.ScanIP(ctx, ip, scan.Ports, s.scanOptions)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Ad-hoc scan: failed to scan %s: %v", ip, err)
+					continue
+				}
+				if len(scan.UDPPorts) > 0 && ctx.Err() == nil {
+					udpResults, err := udpScanner.ScanIP(ctx, ip, scan.UDPPorts, s.scanOptions)
+					if err != nil && ctx.Err() == nil {
+						log.Printf("Ad-hoc scan: failed to scan UDP ports of %s: %v", ip, err)
+					} else {
+						portResults = append(portResults, udpResults...)
+					}
+				}
+
+				scanner.DetectServices(ctx, portResults, s.scanOptions.Timeout)
+
+				mu.Lock()
+				for _, r := range portResults {
+					switch r.Status {
+					case scanner.StatusOpen, scanner.StatusOpenFiltered, scanner.StatusFiltered:
+					default:
+						continue
+					}
+					results = append(results, models.AdhocResult{
+						IPAddress:      r.IP,
+						Port:           r.Port,
+						Protocol:       r.Protocol,
+						Status:         r.Status,
+						ResponseTimeMs: r.ResponseTimeMs,
+						ServiceName:    r.Service.Name,
+						ServiceProduct: r.Service.Product,
+						ServiceVersion: r.Service.Version,
+						Engine:         r.Engine,
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.IPAddress != b.IPAddress {
+			addrA, errA := netip.ParseAddr(a.IPAddress)
+			addrB, errB := netip.ParseAddr(b.IPAddress)
+			if errA == nil && errB == nil {
+				return addrA.Less(addrB)
+			}
+			return a.IPAddress < b.IPAddress
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+
+	return results, ctx.Err()
+}
+
+// StartAdhoc records an ad-hoc scan in adhoc_scans and runs it in the
+// background, returning the job ID. The job is cancelled if the scheduler
+// stops. Only maxAdhocJobs run at once; beyond that ErrTooManyAdhocScans is
+// returned.
+func (s *Scheduler) StartAdhoc(scan AdhocScan, target, requestedBy string) (int, error) {
+	select {
+	case s.adhocSlots <- struct{}{}:
+	default:
+		return 0, ErrTooManyAdhocScans
+	}
+
+	var jobID int
+	err := s.db.QueryRow(`
+		INSERT INTO adhoc_scans (target, ports, udp_ports, engine, status, requested_by)
+		VALUES ($1, $2, $3, $4, 'running', NULLIF($5, ''))
+		RETURNING id
+	`, target, pq.Array(scan.Ports), pq.Array(scan.UDPPorts), s.engineFor(scan.Engine).Name(), requestedBy).Scan(&jobID)
+	if err != nil {
+		<-s.adhocSlots
+		return 0, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.adhocSlots }()
+
+		log.Printf("Ad-hoc scan %d of %s started", jobID, target)
+		results, err := s.runAdhoc(s.ctx, scan)
+
+		status, errMsg := "completed", ""
+		if errors.Is(err, ErrAdhocUnresolved) || errors.Is(err, ErrAdhocTooLarge) {
+			status, errMsg = "failed", err.Error()
+		} else if err != nil {
+			status, errMsg = "cancelled", context.Cause(s.ctx).Error()
+		}
+
+		data, err := json.Marshal(results)
+		if err != nil {
+			status, errMsg = "failed", err.Error()
+			data = nil
+		}
+
+		_, err = s.db.Exec(`
+			UPDATE adhoc_scans
+			SET status = $1, results = $2, error = NULLIF($3, ''), completed_at = NOW()
+			WHERE id = $4
+		`, status, data, errMsg, jobID)
+		if err != nil {
+			log.Printf("Failed to store results of ad-hoc scan %d: %v", jobID, err)
+			return
+		}
+		log.Printf("Ad-hoc scan %d of %s %s: %d results", jobID, target, status, len(results))
+	}()
+
+	return jobID, nil
+}
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ip-scanner/internal/models"
+)
+
+// What happens to a scheduled run that falls in a blackout window
+const (
+	BlackoutSkip  = "skip"  // the run is dropped; the target waits for its next scheduled run
+	BlackoutDefer = "defer" // the run is held until the window closes
+)
+
+// ValidateBlackout checks that a window is either recurring or one-off and
+// that its recurrence, time zone and action are valid
+func ValidateBlackout(w models.BlackoutWindow) error {
+	if w.Action != BlackoutSkip && w.Action != BlackoutDefer {
+		return fmt.Errorf("invalid action: %s (use %s or %s)", w.Action, BlackoutSkip, BlackoutDefer)
+	}
+
+	recurring := w.Recurrence != ""
+	oneOff := w.StartsAt != nil || w.EndsAt != nil
+	switch {
+	case recurring && oneOff:
+		return errors.New("a window has either a recurrence or starts_at and ends_at, not both")
+	case recurring:
+		if w.DurationMinutes <= 0 {
+			return errors.New("duration_minutes is required for a recurring window")
+		}
+		if strings.HasPrefix(w.Recurrence, "CRON_TZ=") {
+			return errors.New("set the time zone with timezone rather than CRON_TZ")
+		}
+		if _, err := blackoutRecurrence(w); err != nil {
+			return err
+		}
+	case oneOff:
+		if w.StartsAt == nil || w.EndsAt == nil || !w.EndsAt.After(*w.StartsAt) {
+			return errors.New("a one-off window needs starts_at before ends_at")
+		}
+	default:
+		return errors.New("a window needs a recurrence or starts_at and ends_at")
+	}
+
+	return nil
+}
+
+// blackoutRecurrence parses the cron recurrence of a window in its time zone
+func blackoutRecurrence(w models.BlackoutWindow) (Schedule, error) {
+	timezone := w.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	schedule, err := ParseSchedule("CRON_TZ=" + timezone + " " + w.Recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recurrence: %v", err)
+	}
+	return schedule, nil
+}
+
+// BlackoutEnd reports whether w is in force at t and, if so, when it closes
+func BlackoutEnd(w models.BlackoutWindow, t time.Time) (time.Time, bool) {
+	if w.Recurrence == "" {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return time.Time{}, false
+		}
+		return *w.EndsAt, !t.Before(*w.StartsAt) && t.Before(*w.EndsAt)
+	}
+
+	schedule, err := blackoutRecurrence(w)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// The window is open if it last opened less than its duration ago
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	opened := schedule.Next(t.Add(-duration))
+	if opened.IsZero() || opened.After(t) {
+		return time.Time{}, false
+	}
+	return opened.Add(duration), true
+}
+
+// loadBlackouts returns the enabled blackout windows
+func (s *Scheduler) loadBlackouts() ([]models.BlackoutWindow, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, target_id, COALESCE(recurrence, ''), COALESCE(duration_minutes, 0),
+		       timezone, starts_at, ends_at, action
+		FROM blackout_windows
+		WHERE enabled = true
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []models.BlackoutWindow{}
+	for rows.Next() {
+		var w models.BlackoutWindow
+		var targetID sql.NullInt64
+		var startsAt, endsAt sql.NullTime
+		err := rows.Scan(
+			&w.ID, &w.Name, &targetID, &w.Recurrence, &w.DurationMinutes,
+			&w.Timezone, &startsAt, &endsAt, &w.Action,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if targetID.Valid {
+			id := int(targetID.Int64)
+			w.TargetID = &id
+		}
+		if startsAt.Valid {
+			w.StartsAt = &startsAt.Time
+		}
+		if endsAt.Valid {
+			w.EndsAt = &endsAt.Time
+		}
+		w.Enabled = true
+
+		windows = append(windows, w)
+	}
+
+	return windows, rows.Err()
+}
+
+// applyBlackouts holds back due targets that fall in a blackout window. It
+// returns the targets to scan now and a line for each target newly held
+// back, for the skipped run's skip_reason. A skipped target counts as run, so
+// it waits for its next scheduled run; a deferred one stays due and is
+// reported once per window.
+func (s *Scheduler) applyBlackouts(targets []scanTarget) ([]scanTarget, []string) {
+	windows, err := s.loadBlackouts()
+	if err != nil {
+		log.Printf("Failed to fetch blackout windows, scanning regardless: %v", err)
+		return targets, nil
+	}
+
+	now := time.Now()
+	scan := []scanTarget{}
+	var reasons []string
+
+	for _, t := range targets {
+		var window models.BlackoutWindow
+		var until time.Time
+		blacked := false
+		for _, w := range windows {
+			if w.TargetID != nil && *w.TargetID != t.id {
+				continue
+			}
+			if end, ok := BlackoutEnd(w, now); ok {
+				window, until, blacked = w, end, true
+				break
+			}
+		}
+
+		if !blacked {
+			delete(s.deferred, t.id)
+			scan = append(scan, t)
+			continue
+		}
+
+		switch window.Action {
+		case BlackoutSkip:
+			s.markTargetsScheduled([]scanTarget{t}, now)
+			reasons = append(reasons, fmt.Sprintf("%s skipped: blackout window %q until %s",
+				t.target, window.Name, until.UTC().Format(time.RFC3339)))
+		default:
+			if s.deferred[t.id] == window.ID {
+				continue
+			}
+			s.deferred[t.id] = window.ID
+			reasons = append(reasons, fmt.Sprintf("%s deferred: blackout window %q until %s",
+				t.target, window.Name, until.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	for _, reason := range reasons {
+		log.Printf("Target %s", reason)
+	}
+
+	return scan, reasons
+}
+
+// recordSkippedRun records the due targets held back by a blackout window
+// as a skipped run
+func (s *Scheduler) recordSkippedRun(reasons []string) {
+	_, err := s.db.Exec(`
+		INSERT INTO scan_sessions (started_at, completed_at, status, skip_reason)
+		VALUES (NOW(), NOW(), 'skipped', $1)
+	`, strings.Join(reasons, "\n"))
+	if err != nil {
+		log.Printf("Failed to record skipped scan: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"ip-scanner/internal/models"
+)
+
+func TestBlackoutEnd(t *testing.T) {
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	at := func(t time.Time) *time.Time { return &t }
+
+	oneOff := models.BlackoutWindow{StartsAt: at(date(1, 1, 10, 0)), EndsAt: at(date(1, 1, 12, 0))}
+	nightly := models.BlackoutWindow{Recurrence: "0 22 * * *", DurationMinutes: 120, Timezone: "UTC"}
+	london := models.BlackoutWindow{Recurrence: "0 9 * * *", DurationMinutes: 60, Timezone: "Europe/London"}
+
+	tests := []struct {
+		name   string
+		window models.BlackoutWindow
+		at     time.Time
+		want   time.Time
+		active bool
+	}{
+		{"one-off before", oneOff, date(1, 1, 9, 59), time.Time{}, false},
+		{"one-off start", oneOff, date(1, 1, 10, 0), date(1, 1, 12, 0), true},
+		{"one-off during", oneOff, date(1, 1, 11, 0), date(1, 1, 12, 0), true},
+		{"one-off end", oneOff, date(1, 1, 12, 0), time.Time{}, false},
+		{"one-off without end", models.BlackoutWindow{StartsAt: at(date(1, 1, 10, 0))}, date(1, 1, 11, 0), time.Time{}, false},
+		{"recurring before", nightly, date(1, 1, 21, 59), time.Time{}, false},
+		{"recurring opening", nightly, date(1, 1, 22, 0), date(1, 2, 0, 0), true},
+		{"recurring across midnight", nightly, date(1, 1, 23, 30), date(1, 2, 0, 0), true},
+		{"recurring closing", nightly, date(1, 2, 0, 0), time.Time{}, false},
+		{"recurring after", nightly, date(1, 2, 0, 30), time.Time{}, false},
+		{"default time zone", models.BlackoutWindow{Recurrence: "0 22 * * *", DurationMinutes: 120}, date(1, 1, 23, 0), date(1, 2, 0, 0), true},
+		// 09:00 in London is 08:00 UTC in summer
+		{"time zone during", london, date(7, 1, 8, 30), date(7, 1, 9, 0), true},
+		{"time zone after", london, date(7, 1, 9, 30), time.Time{}, false},
+		{"invalid recurrence", models.BlackoutWindow{Recurrence: "bogus", DurationMinutes: 60}, date(1, 1, 0, 0), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, active := BlackoutEnd(tt.window, tt.at)
+		if active != tt.active || (active && !got.Equal(tt.want)) {
+			t.Errorf("%s: BlackoutEnd() = %v, %v, want %v, %v", tt.name, got, active, tt.want, tt.active)
+		}
+	}
+}
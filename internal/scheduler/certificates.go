@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"ip-scanner/internal/scanner"
+
+	"github.com/lib/pq"
+)
+
+// recordCertificate stores the certificate seen on ip:port and raises
+// notifications when it changes or is close to (or past) expiry
+func (s *Scheduler) recordCertificate(sessionID, targetID int, ip string, port int, cert *scanner.CertificateInfo) {
+	var previousFingerprint string
+	var expiryNotified sql.NullString
+	err := s.db.QueryRow(`
+		SELECT fingerprint_sha256, expiry_notified
+		FROM tls_certificates
+		WHERE ip_address = $1 AND port = $2
+	`, ip, port).Scan(&previousFingerprint, &expiryNotified)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to fetch previous certificate for %s:%d: %v", ip, port, err)
+		return
+	}
+
+	if err == nil && previousFingerprint != cert.FingerprintSHA256 {
+		s.insertNotification(sessionID, targetID, ip, port, "cert_changed",
+			"TLS Certificate Changed",
+			fmt.Sprintf("Certificate on %s:%d changed (now %s, issued by %s)", ip, port, cert.Subject, cert.Issuer),
+			"warning")
+		// A new certificate gets its own expiry notifications
+		expiryNotified = sql.NullString{}
+	}
+
+	chain, err := json.Marshal(cert.Chain)
+	if err != nil {
+		log.Printf("Failed to encode certificate chain for %s:%d: %v", ip, port, err)
+		return
+	}
+
+	expiryState := certificateExpiryState(cert.NotAfter, s.certExpiryDays)
+	if expiryState != "" && expiryState != expiryNotified.String {
+		s.notifyCertificateExpiry(sessionID, targetID, ip, port, cert, expiryState)
+		expiryNotified = sql.NullString{String: expiryState, Valid: true}
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO tls_certificates (
+			target_id, ip_address, port, subject, issuer, sans, not_before, not_after,
+			key_type, key_size, self_signed, tls_version, cipher_suite,
+			fingerprint_sha256, chain, expiry_notified, first_seen_at, last_seen_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW(), NOW())
+		ON CONFLICT (ip_address, port) DO UPDATE SET
+			target_id = EXCLUDED.target_id,
+			subject = EXCLUDED.subject,
+			issuer = EXCLUDED.issuer,
+			sans = EXCLUDED.sans,
+			not_before = EXCLUDED.not_before,
+			not_after = EXCLUDED.not_after,
+			key_type = EXCLUDED.key_type,
+			key_size = EXCLUDED.key_size,
+			self_signed = EXCLUDED.self_signed,
+			tls_version = EXCLUDED.tls_version,
+			cipher_suite = EXCLUDED.cipher_suite,
+			fingerprint_sha256 = EXCLUDED.fingerprint_sha256,
+			chain = EXCLUDED.chain,
+			expiry_notified = EXCLUDED.expiry_notified,
+			first_seen_at = CASE
+				WHEN tls_certificates.fingerprint_sha256 = EXCLUDED.fingerprint_sha256
+				THEN tls_certificates.first_seen_at
+				ELSE NOW()
+			END,
+			last_seen_at = NOW()
+	`, targetID, ip, port, cert.Subject, cert.Issuer, pq.Array(cert.SANs),
+		cert.NotBefore, cert.NotAfter, cert.KeyType, cert.KeySize, cert.SelfSigned,
+		cert.TLSVersion, cert.CipherSuite, cert.FingerprintSHA256, chain, expiryNotified)
+	if err != nil {
+		log.Printf("Failed to store certificate for %s:%d: %v", ip, port, err)
+	}
+}
+
+// certificateExpiryState returns "expired", "expiring" (within warningDays)
+// or "" for a healthy certificate
+func certificateExpiryState(notAfter time.Time, warningDays int) string {
+	now := time.Now()
+	switch {
+	case now.After(notAfter):
+		return "expired"
+	case now.AddDate(0, 0, warningDays).After(notAfter):
+		return "expiring"
+	default:
+		return ""
+	}
+}
+
+func (s *Scheduler) notifyCertificateExpiry(sessionID, targetID int, ip string, port int, cert *scanner.CertificateInfo, state string) {
+	expiry := cert.NotAfter.UTC().Format("2006-01-02")
+
+	if state == "expired" {
+		s.insertNotification(sessionID, targetID, ip, port, "cert_expired",
+			"TLS Certificate Expired",
+			fmt.Sprintf("Certificate %s on %s:%d expired on %s", cert.Subject, ip, port, expiry),
+			"critical")
+		return
+	}
+
+	days := int(time.Until(cert.NotAfter).Hours() / 24)
+	s.insertNotification(sessionID, targetID, ip, port, "cert_expiring",
+		"TLS Certificate Expiring Soon",
+		fmt.Sprintf("Certificate %s on %s:%d expires in %d days (%s)", cert.Subject, ip, port, days, expiry),
+		"warning")
+}
@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCertificateExpiryState(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		expiresIn   time.Duration
+		warningDays int
+		want        string
+	}{
+		{"expired", -time.Hour, 30, "expired"},
+		{"expired long ago", -400 * day, 30, "expired"},
+		{"expiring", 10 * day, 30, "expiring"},
+		{"expiring at the edge", 29 * day, 30, "expiring"},
+		{"healthy", 31 * day, 30, ""},
+		{"no warning period", 10 * day, 0, ""},
+	}
+
+	for _, tt := range tests {
+		if got := certificateExpiryState(time.Now().Add(tt.expiresIn), tt.warningDays); got != tt.want {
+			t.Errorf("%s: certificateExpiryState() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
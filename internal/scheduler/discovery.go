@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"ip-scanner/internal/scanner"
+)
+
+// discoveryTimeout bounds each discovery probe. Hosts that don't answer
+// within it are treated as down and not port scanned.
+const discoveryTimeout = 1 * time.Second
+
+// recordDiscovery stores whether a host answered the discovery probe in this session
+func (s *Scheduler) recordDiscovery(sessionID, targetID int, result scanner.DiscoveryResult) {
+	_, err := s.db.Exec(`
+		INSERT INTO host_discovery (session_id, target_id, ip_address, is_up, method, discovered_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`, sessionID, targetID, result.IP, result.Up, result.Method)
+	if err != nil {
+		log.Printf("Failed to record discovery for %s: %v", result.IP, err)
+	}
+}
+
+// unreachablePorts returns the ports of t last seen open on ip as
+// unreachable results, for a host that no longer answers discovery. TCP
+// ports are attributed to engine so their verification uses it.
+func (s *Scheduler) unreachablePorts(t scanTarget, ip, engine string) []scanner.PortScanResult {
+	udpPorts := []int{}
+	if t.scanUDP {
+		udpPorts = t.udpPorts
+	}
+
+	rows, err := s.db.Query(`
+		SELECT port, protocol FROM port_state
+		WHERE ip_address = $1 AND status = 'open'
+		  AND ((protocol = 'tcp' AND port = ANY($2)) OR (protocol = 'udp' AND port = ANY($3)))
+		ORDER BY protocol, port
+	`, ip, pq.Array(t.ports), pq.Array(udpPorts))
+	if err != nil {
+		log.Printf("Failed to fetch open ports of %s: %v", ip, err)
+		return nil
+	}
+	defer rows.Close()
+
+	results := []scanner.PortScanResult{}
+	for rows.Next() {
+		result := scanner.PortScanResult{IP: ip, Status: scanner.StatusUnreachable, Engine: engine}
+		if err := rows.Scan(&result.Port, &result.Protocol); err != nil {
+			log.Printf("Failed to scan open port of %s: %v", ip, err)
+			continue
+		}
+		if result.Protocol == scanner.ProtocolUDP {
+			result.Engine = scanner.EngineUDP
+		}
+		results = append(results, result)
+	}
+	return results
+}
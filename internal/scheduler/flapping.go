@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+)
+
+// recordTransition notes that a port went from open to not open or back,
+// as classified by portChange, so a UDP probe without a reply never counts
+// towards flapping. It reports whether alerts for the transition should be suppressed
+// because the port is flapping. A port flaps once it has made flapThreshold
+// transitions within flapWindow; that raises a single port_flapping
+// notification and drops any closure verification waiting for it.
+// previousStatus is the status the port left; on the transition that marks
+// it flapping it is kept as the last state alerted on, for settleFlaps.
+func (s *Scheduler) recordTransition(sessionID, targetID int, ip string, port int, protocol, previousStatus string) bool {
+	windowMinutes := int(s.flapWindow.Minutes())
+
+	var transitions int
+	var flapping bool
+	err := s.db.QueryRow(`
+		INSERT INTO port_flaps (target_id, ip_address, port, protocol, transitions, last_transition_at)
+		VALUES ($1, $2, $3, $4, ARRAY[NOW()::timestamp], NOW())
+		ON CONFLICT (ip_address, port, protocol) DO UPDATE SET
+			target_id = EXCLUDED.target_id,
+			transitions = ARRAY(
+				SELECT t FROM unnest(port_flaps.transitions || NOW()::timestamp) AS t
+				WHERE t > NOW() - $5 * INTERVAL '1 minute'
+				ORDER BY t
+			),
+			last_transition_at = NOW()
+		RETURNING cardinality(transitions), flapping
+	`, targetID, ip, port, protocol, windowMinutes).Scan(&transitions, &flapping)
+	if err != nil {
+		log.Printf("Failed to record transition of %s:%d/%s: %v", ip, port, protocol, err)
+		return false
+	}
+
+	if flapping {
+		log.Printf("Suppressing alert for flapping port %s:%d/%s", ip, port, protocol)
+		return true
+	}
+	if s.flapThreshold <= 0 || transitions < s.flapThreshold {
+		return false
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE port_flaps SET flapping = true, flapping_since = NOW(), status_before = $4
+		WHERE ip_address = $1 AND port = $2 AND protocol = $3
+	`, ip, port, protocol, previousStatus)
+	if err != nil {
+		log.Printf("Failed to mark %s:%d/%s as flapping: %v", ip, port, protocol, err)
+		return false
+	}
+
+	_, err = s.db.Exec(`
+		DELETE FROM port_verifications
+		WHERE ip_address = $1 AND port = $2 AND protocol = $3
+	`, ip, port, protocol)
+	if err != nil {
+		log.Printf("Failed to drop verification for %s:%d/%s: %v", ip, port, protocol, err)
+	}
+
+	s.insertNotification(sessionID, targetID, ip, port, "port_flapping",
+		"Port Flapping",
+		fmt.Sprintf("Port %d/%s on %s changed state %d times in %v; alerts are suppressed until it settles",
+			port, protocol, ip, transitions, s.flapWindow),
+		"warning")
+
+	return true
+}
+
+// settledFlap is a port that stopped flapping, with the status it had when
+// it started and its current state
+type settledFlap struct {
+	targetID     int
+	ip           string
+	port         int
+	protocol     string
+	statusBefore string
+	status       string
+	sessionID    int
+	engine       string
+}
+
+// settleFlaps clears the flapping state of ports that haven't changed state
+// for a whole window, so their next transition alerts again. Alerts were
+// suppressed while a port flapped, so one that settled on the other side of
+// open from where it started is alerted once now: a port that opened is
+// notified, and one that closed gets a verification like any other closure.
+func (s *Scheduler) settleFlaps() {
+	rows, err := s.db.Query(`
+		WITH settled AS (
+			UPDATE port_flaps SET flapping = false, flapping_since = NULL, transitions = '{}'
+			WHERE flapping AND last_transition_at <= NOW() - $1 * INTERVAL '1 minute'
+			RETURNING ip_address, port, protocol, target_id, status_before
+		)
+		SELECT COALESCE(se.target_id, 0), host(se.ip_address), se.port, se.protocol,
+		       COALESCE(se.status_before, ''), COALESCE(ps.status, ''),
+		       COALESCE(ps.session_id, 0), COALESCE(ps.engine, '')
+		FROM settled se
+		LEFT JOIN port_state ps
+			ON ps.ip_address = se.ip_address AND ps.port = se.port AND ps.protocol = se.protocol
+	`, int(s.flapWindow.Minutes()))
+	if err != nil {
+		log.Printf("Failed to settle flapping ports: %v", err)
+		return
+	}
+
+	settled := []settledFlap{}
+	for rows.Next() {
+		var f settledFlap
+		err := rows.Scan(&f.targetID, &f.ip, &f.port, &f.protocol, &f.statusBefore, &f.status, &f.sessionID, &f.engine)
+		if err != nil {
+			log.Printf("Failed to scan settled port: %v", err)
+			continue
+		}
+		settled = append(settled, f)
+	}
+	rows.Close()
+
+	for _, f := range settled {
+		log.Printf("Port %s:%d/%s stopped flapping", f.ip, f.port, f.protocol)
+
+		// Ports marked flapping before the status was kept have nothing to compare
+		if f.statusBefore == "" {
+			continue
+		}
+		switch portChange(f.statusBefore, f.status) {
+		case "new":
+			s.createNotification(f.sessionID, f.targetID, f.ip, f.port, f.protocol, "new_port")
+		case "closed":
+			s.schedulePortVerification(f.sessionID, f.targetID, f.ip, f.port, f.protocol, f.engine)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"ip-scanner/internal/scanner"
+
+	"github.com/lib/pq"
+)
+
+// ptrCacheTTL is how long a reverse lookup is trusted before it is repeated
+const ptrCacheTTL = 24 * time.Hour
+
+// enrichHost refreshes the cached PTR names of ip if they are missing or stale
+func (s *Scheduler) enrichHost(ctx context.Context, ip string) {
+	var resolvedAt *time.Time
+	err := s.db.QueryRow(`
+		SELECT ptr_resolved_at FROM hosts WHERE ip_address = $1
+	`, ip).Scan(&resolvedAt)
+	if err == nil && resolvedAt != nil && time.Since(*resolvedAt) < ptrCacheTTL {
+		return
+	}
+
+	names, err := scanner.ReverseLookup(ctx, ip)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			log.Printf("Reverse lookup failed for %s: %v", ip, err)
+			return
+		}
+		// No PTR record is an answer too; cache it
+		names = []string{}
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO hosts (ip_address, ptr_names, ptr_resolved_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (ip_address) DO UPDATE SET
+			ptr_names = EXCLUDED.ptr_names,
+			ptr_resolved_at = NOW(),
+			updated_at = NOW()
+	`, ip, pq.Array(names))
+	if err != nil {
+		log.Printf("Failed to store host names for %s: %v", ip, err)
+	}
+}
+
+// refreshCertificateNames caches the DNS SANs of every certificate currently
+// presented on ip, so a replaced certificate doesn't leave stale names behind
+func (s *Scheduler) refreshCertificateNames(ip string) {
+	var sans []string
+	err := s.db.QueryRow(`
+		SELECT ARRAY(
+			SELECT DISTINCT lower(san)
+			FROM tls_certificates, unnest(sans) AS san
+			WHERE ip_address = $1
+		)
+	`, ip).Scan(pq.Array(&sans))
+	if err != nil {
+		log.Printf("Failed to fetch certificate names for %s: %v", ip, err)
+		return
+	}
+
+	names := []string{}
+	for _, san := range sans {
+		// Skip IP SANs and wildcards, neither is a usable host name
+		if net.ParseIP(san) != nil || strings.HasPrefix(san, "*.") {
+			continue
+		}
+		names = append(names, san)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO hosts (ip_address, cert_names, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (ip_address) DO UPDATE SET
+			cert_names = EXCLUDED.cert_names,
+			updated_at = NOW()
+	`, ip, pq.Array(names))
+	if err != nil {
+		log.Printf("Failed to store certificate names for %s: %v", ip, err)
+	}
+}
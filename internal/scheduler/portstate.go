@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"database/sql"
+
+	"ip-scanner/internal/scanner"
+)
+
+// portChange classifies a move from previous to current status for the
+// session summary: "new" into open, including a port open on first sight,
+// "closed" out of open, and "" otherwise. An indeterminate status is never a
+// change.
+func portChange(previous, current string) string {
+	if !scanner.Determinate(previous) || !scanner.Determinate(current) {
+		return ""
+	}
+	if current == scanner.StatusOpen && previous != scanner.StatusOpen {
+		return "new"
+	}
+	if previous == scanner.StatusOpen && current != scanner.StatusOpen {
+		return "closed"
+	}
+	return ""
+}
+
+// recordPortResult stores a probe result and returns the port's previous
+// status, empty on first sight. The current state in port_state is updated
+// in place; port_events only gets a row when the status changes; otherwise
+// the event that began the current state is marked as seen again. An
+// indeterminate result leaves a known state as it is and only marks it seen.
+func (s *Scheduler) recordPortResult(sessionID, targetID int, result scanner.PortScanResult) (string, error) {
+	var previousStatus string
+	var eventID sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT status, event_id FROM port_state
+		WHERE ip_address = $1 AND port = $2 AND protocol = $3
+	`, result.IP, result.Port, result.Protocol).Scan(&previousStatus, &eventID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if previousStatus != "" && !scanner.Determinate(result.Status) {
+		if eventID.Valid {
+			_, err = s.db.Exec(`
+				UPDATE port_events SET last_seen_at = NOW(), last_session_id = $1
+				WHERE id = $2
+			`, sessionID, eventID.Int64)
+			if err != nil {
+				return previousStatus, err
+			}
+		}
+		_, err = s.db.Exec(`
+			UPDATE port_state SET session_id = $1, last_seen_at = NOW()
+			WHERE ip_address = $2 AND port = $3 AND protocol = $4
+		`, sessionID, result.IP, result.Port, result.Protocol)
+		return previousStatus, err
+	}
+
+	if previousStatus == result.Status && eventID.Valid {
+		_, err = s.db.Exec(`
+			UPDATE port_events SET last_seen_at = NOW(), last_session_id = $1
+			WHERE id = $2
+		`, sessionID, eventID.Int64)
+	} else {
+		// Note whether the port opened or closed, for the session summary
+		change := portChange(previousStatus, result.Status)
+
+		err = s.db.QueryRow(`
+			INSERT INTO port_events (session_id, last_session_id, target_id, ip_address, port, protocol,
+				previous_status, status, change, response_time_ms, service_name, service_product, service_version, engine)
+			VALUES ($1, $1, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, ''), $9,
+				NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), $13)
+			RETURNING id
+		`, sessionID, targetID, result.IP, result.Port, result.Protocol,
+			previousStatus, result.Status, change, result.ResponseTimeMs,
+			result.Service.Name, result.Service.Product, result.Service.Version, result.Engine).Scan(&eventID)
+	}
+	if err != nil {
+		return previousStatus, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO port_state (ip_address, port, protocol, target_id, event_id, session_id, status,
+			response_time_ms, service_name, service_product, service_version, engine,
+			first_open_at, changed_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12,
+			CASE WHEN $13 THEN NOW() END, NOW(), NOW())
+		ON CONFLICT (ip_address, port, protocol) DO UPDATE SET
+			target_id = EXCLUDED.target_id,
+			event_id = EXCLUDED.event_id,
+			session_id = EXCLUDED.session_id,
+			status = EXCLUDED.status,
+			response_time_ms = EXCLUDED.response_time_ms,
+			service_name = EXCLUDED.service_name,
+			service_product = EXCLUDED.service_product,
+			service_version = EXCLUDED.service_version,
+			engine = EXCLUDED.engine,
+			first_open_at = COALESCE(port_state.first_open_at, EXCLUDED.first_open_at),
+			changed_at = CASE WHEN port_state.status = EXCLUDED.status THEN port_state.changed_at ELSE NOW() END,
+			last_seen_at = NOW()
+	`, result.IP, result.Port, result.Protocol, targetID, eventID, sessionID, result.Status,
+		result.ResponseTimeMs, result.Service.Name, result.Service.Product, result.Service.Version, result.Engine,
+		result.Status == scanner.StatusOpen)
+
+	return previousStatus, err
+}
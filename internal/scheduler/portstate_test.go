@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"testing"
+
+	"ip-scanner/internal/scanner"
+)
+
+func TestPortChange(t *testing.T) {
+	tests := []struct {
+		previous, current string
+		want              string
+	}{
+		{"", scanner.StatusOpen, "new"},
+		{"", scanner.StatusClosed, ""},
+		{scanner.StatusClosed, scanner.StatusOpen, "new"},
+		{scanner.StatusFiltered, scanner.StatusOpen, "new"},
+		{scanner.StatusOpen, scanner.StatusClosed, "closed"},
+		{scanner.StatusOpen, scanner.StatusUnreachable, "closed"},
+		{scanner.StatusOpen, scanner.StatusOpen, ""},
+		{scanner.StatusClosed, scanner.StatusFiltered, ""},
+		// A UDP probe without a reply is neither open nor closed
+		{scanner.StatusOpen, scanner.StatusOpenFiltered, ""},
+		{scanner.StatusOpenFiltered, scanner.StatusOpen, ""},
+		{scanner.StatusOpenFiltered, scanner.StatusClosed, ""},
+		{"", scanner.StatusOpenFiltered, ""},
+	}
+
+	for _, tt := range tests {
+		if got := portChange(tt.previous, tt.current); got != tt.want {
+			t.Errorf("portChange(%q, %q) = %q, want %q", tt.previous, tt.current, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of the scans in progress. With several sessions
+// running, the top level adds up their counts, takes the session, start and
+// current target of the one that started first and the longest ETA, and
+// Sessions lists each one.
+type Progress struct {
+	Scanning      bool       `json:"scanning"`
+	SessionID     int        `json:"session_id,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	CurrentTarget string     `json:"current_target,omitempty"`
+	TargetsDone   int        `json:"targets_done"`
+	TargetsTotal  int        `json:"targets_total"`
+	IPsDone       uint64     `json:"ips_done"`
+	IPsTotal      uint64     `json:"ips_total"`
+	PortsChecked  int        `json:"ports_checked"`
+	OpenPorts     int        `json:"open_ports"`
+	ETASeconds    *int       `json:"eta_seconds,omitempty"`
+	Sessions      []Progress `json:"sessions,omitempty"`
+}
+
+// progressTracker holds the live progress of each running scan session.
+// Workers update it after every IP, so it is kept separate from scanningMux.
+type progressTracker struct {
+	mu       sync.Mutex
+	sessions map[int]*Progress
+}
+
+func (p *progressTracker) start(sessionID, targetsTotal int, ipsTotal uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sessions == nil {
+		p.sessions = make(map[int]*Progress)
+	}
+	now := time.Now()
+	p.sessions[sessionID] = &Progress{
+		Scanning:     true,
+		SessionID:    sessionID,
+		StartedAt:    &now,
+		TargetsTotal: targetsTotal,
+		IPsTotal:     ipsTotal,
+	}
+}
+
+// update applies fn to the progress of a running session
+func (p *progressTracker) update(sessionID int, fn func(*Progress)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if progress, ok := p.sessions[sessionID]; ok {
+		fn(progress)
+	}
+}
+
+// setTarget records the target being scanned. Host names only contribute
+// addresses once resolved, so the IP total is corrected here.
+func (p *progressTracker) setTarget(sessionID int, target string, countBefore, countAfter uint64) {
+	p.update(sessionID, func(progress *Progress) {
+		progress.CurrentTarget = target
+		progress.IPsTotal = progress.IPsTotal - countBefore + countAfter
+	})
+}
+
+func (p *progressTracker) ipDone(sessionID, portsChecked, openPorts int) {
+	p.update(sessionID, func(progress *Progress) {
+		progress.IPsDone++
+		progress.PortsChecked += portsChecked
+		progress.OpenPorts += openPorts
+	})
+}
+
+func (p *progressTracker) targetDone(sessionID int) {
+	p.update(sessionID, func(progress *Progress) {
+		progress.TargetsDone++
+		progress.CurrentTarget = ""
+	})
+}
+
+func (p *progressTracker) finish(sessionID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.sessions, sessionID)
+}
+
+// snapshot returns a copy of the progress of every session, each with its
+// ETA extrapolated from the rate at which IPs have been completed so far,
+// and their total
+func (p *progressTracker) snapshot() Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var total Progress
+	for _, session := range p.sessions {
+		progress := *session
+		startedAt := *progress.StartedAt
+		progress.StartedAt = &startedAt
+
+		if progress.IPsDone > 0 && progress.IPsTotal >= progress.IPsDone {
+			perIP := time.Since(startedAt) / time.Duration(progress.IPsDone)
+			eta := int((perIP * time.Duration(progress.IPsTotal-progress.IPsDone)).Seconds())
+			progress.ETASeconds = &eta
+		}
+		total.Sessions = append(total.Sessions, progress)
+	}
+	sort.Slice(total.Sessions, func(i, j int) bool {
+		return total.Sessions[i].SessionID < total.Sessions[j].SessionID
+	})
+
+	for i, progress := range total.Sessions {
+		if i == 0 {
+			total.SessionID = progress.SessionID
+			total.StartedAt = progress.StartedAt
+			total.CurrentTarget = progress.CurrentTarget
+		}
+		total.TargetsDone += progress.TargetsDone
+		total.TargetsTotal += progress.TargetsTotal
+		total.IPsDone += progress.IPsDone
+		total.IPsTotal += progress.IPsTotal
+		total.PortsChecked += progress.PortsChecked
+		total.OpenPorts += progress.OpenPorts
+		if progress.ETASeconds != nil && (total.ETASeconds == nil || *progress.ETASeconds > *total.ETASeconds) {
+			eta := *progress.ETASeconds
+			total.ETASeconds = &eta
+		}
+	}
+
+	return total
+}
+
+// Progress returns a snapshot of the scans in progress
+func (s *Scheduler) Progress() Progress {
+	progress := s.progress.snapshot()
+	progress.Scanning = s.IsScanning()
+	return progress
+}
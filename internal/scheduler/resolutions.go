@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"ip-scanner/internal/scanner"
+
+	"github.com/lib/pq"
+)
+
+// recordResolutions stores what each host name of a target resolved to in
+// this session and notifies when a name's address set changed since the last
+// successful lookup
+func (s *Scheduler) recordResolutions(sessionID, targetID int, resolutions []scanner.Resolution) {
+	for _, res := range resolutions {
+		if res.Err != nil {
+			log.Printf("Failed to resolve %s: %v", res.Hostname, res.Err)
+			_, err := s.db.Exec(`
+				INSERT INTO dns_resolutions (session_id, target_id, hostname, error)
+				VALUES ($1, $2, $3, $4)
+			`, sessionID, targetID, res.Hostname, res.Err.Error())
+			if err != nil {
+				log.Printf("Failed to store resolution for %s: %v", res.Hostname, err)
+			}
+			continue
+		}
+
+		// Compare against the last successful lookup; failures are recorded
+		// but don't count as a change
+		var previous []string
+		err := s.db.QueryRow(`
+			SELECT ARRAY(SELECT host(a) FROM unnest(addresses) AS a ORDER BY a)
+			FROM dns_resolutions
+			WHERE target_id = $1 AND hostname = $2 AND error IS NULL
+			ORDER BY resolved_at DESC
+			LIMIT 1
+		`, targetID, res.Hostname).Scan(pq.Array(&previous))
+		hasPrevious := err == nil
+
+		_, err = s.db.Exec(`
+			INSERT INTO dns_resolutions (session_id, target_id, hostname, addresses)
+			VALUES ($1, $2, $3, $4::inet[])
+		`, sessionID, targetID, res.Hostname, pq.Array(res.Addresses))
+		if err != nil {
+			log.Printf("Failed to store resolution for %s: %v", res.Hostname, err)
+			continue
+		}
+
+		if hasPrevious {
+			added, removed := diffAddresses(previous, res.Addresses)
+			if len(added) > 0 || len(removed) > 0 {
+				s.notifyResolutionChange(sessionID, targetID, res.Hostname, added, removed)
+			}
+		}
+	}
+}
+
+// diffAddresses returns the addresses only in current (added) and only in
+// previous (removed)
+func diffAddresses(previous, current []string) (added, removed []string) {
+	inPrevious := make(map[string]bool, len(previous))
+	for _, ip := range previous {
+		inPrevious[ip] = true
+	}
+
+	inCurrent := make(map[string]bool, len(current))
+	for _, ip := range current {
+		inCurrent[ip] = true
+		if !inPrevious[ip] {
+			added = append(added, ip)
+		}
+	}
+
+	for _, ip := range previous {
+		if !inCurrent[ip] {
+			removed = append(removed, ip)
+		}
+	}
+
+	return added, removed
+}
+
+func (s *Scheduler) notifyResolutionChange(sessionID, targetID int, hostname string, added, removed []string) {
+	var changes []string
+	if len(added) > 0 {
+		changes = append(changes, "added "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, "removed "+strings.Join(removed, ", "))
+	}
+
+	s.insertNotification(sessionID, targetID, "", 0, "dns_changed",
+		"DNS Resolution Changed",
+		fmt.Sprintf("%s now resolves differently: %s", hostname, strings.Join(changes, "; ")),
+		"info")
+}
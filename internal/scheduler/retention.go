@@ -0,0 +1,294 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ip-scanner/internal/models"
+)
+
+// retentionBatch bounds the rows removed per DELETE, so pruning a large
+// backlog doesn't hold long locks
+const retentionBatch = 10000
+
+var errRetentionStopped = errors.New("stopped before finishing")
+
+// RetentionScheduler periodically prunes old scan data. Scan sessions are
+// compacted into daily rollups before they are pruned. Each run is recorded
+// in retention_runs with the rows it pruned from each table.
+type RetentionScheduler struct {
+	db       *sql.DB
+	interval time.Duration
+	policy   models.RetentionPolicy
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewRetentionScheduler(db *sql.DB, interval time.Duration) *RetentionScheduler {
+	return &RetentionScheduler{
+		db:       db,
+		interval: interval,
+		policy: models.RetentionPolicy{
+			RawDays:          getEnvIntAtLeast("RETENTION_RAW_DAYS", 30, 0),
+			RollupDays:       getEnvIntAtLeast("RETENTION_ROLLUP_DAYS", 365, 0),
+			NotificationDays: getEnvIntAtLeast("RETENTION_NOTIFICATION_DAYS", 90, 0),
+		},
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Policy returns how long each kind of data is kept
+func (s *RetentionScheduler) Policy() models.RetentionPolicy {
+	return s.policy
+}
+
+// Start begins the scheduled retention runs
+func (s *RetentionScheduler) Start() {
+	s.wg.Add(1)
+	go s.run()
+	log.Printf("Retention scheduler started with interval: %v (raw data %s, rollups %s, notifications %s)",
+		s.interval, keptFor(s.policy.RawDays), keptFor(s.policy.RollupDays), keptFor(s.policy.NotificationDays))
+}
+
+// keptFor describes a retention period in days, where 0 keeps data forever
+func keptFor(days int) string {
+	if days == 0 {
+		return "kept forever"
+	}
+	return fmt.Sprintf("kept %d days", days)
+}
+
+// Stop gracefully stops the scheduler, interrupting a run in progress
+// between batches
+func (s *RetentionScheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	log.Println("Retention scheduler stopped")
+}
+
+func (s *RetentionScheduler) run() {
+	defer s.wg.Done()
+
+	// Run first pass after 5 minutes, clear of startup and the first scan
+	select {
+	case <-time.After(5 * time.Minute):
+		s.performRetention()
+	case <-s.stopCh:
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.performRetention()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *RetentionScheduler) performRetention() {
+	log.Println("Starting data retention run...")
+
+	var runID int
+	err := s.db.QueryRow(`
+		INSERT INTO retention_runs (started_at, status)
+		VALUES (NOW(), 'running')
+		RETURNING id
+	`).Scan(&runID)
+	if err != nil {
+		log.Printf("Failed to record retention run: %v", err)
+		return
+	}
+
+	pruned := map[string]int64{}
+	status, message := "completed", ""
+	if err := s.compact(pruned); err != nil {
+		status, message = "failed", err.Error()
+		log.Printf("Data retention run failed: %v", err)
+	}
+
+	counts, _ := json.Marshal(pruned)
+	_, err = s.db.Exec(`
+		UPDATE retention_runs
+		SET completed_at = NOW(), status = $1, rows_pruned = $2, error = NULLIF($3, '')
+		WHERE id = $4
+	`, status, string(counts), message, runID)
+	if err != nil {
+		log.Printf("Failed to update retention run: %v", err)
+	}
+
+	var total int64
+	for _, n := range pruned {
+		total += n
+	}
+	log.Printf("Data retention run %s: %d rows pruned %v", status, total, pruned)
+}
+
+// compact rolls up scan sessions and prunes whatever is past its retention
+// period, adding the rows removed to pruned by table
+func (s *RetentionScheduler) compact(pruned map[string]int64) error {
+	// Days before today are complete. They are rolled up again while their
+	// sessions are within the raw retention period, and days past it only
+	// once, since some of their sessions may already be pruned. Each target
+	// is scanned in its own session, so a day's open ports and hosts up add
+	// up the last completed session of each target that day; sessions from
+	// before that covered every target and count as one.
+	_, err := s.db.Exec(`
+		INSERT INTO daily_rollups (day, sessions, completed_sessions, ports_scanned,
+			open_ports, new_ports, closed_ports, hosts_up, updated_at)
+		SELECT day, COUNT(*), COUNT(*) FILTER (WHERE status = 'completed'),
+		       COALESCE(SUM(ports_scanned), 0),
+		       COALESCE(SUM(open_ports) FILTER (WHERE status = 'completed' AND recency = 1), 0),
+		       COALESCE(SUM(new_ports), 0), COALESCE(SUM(closed_ports), 0),
+		       COALESCE(SUM(hosts_up) FILTER (WHERE status = 'completed' AND recency = 1), 0), NOW()
+		FROM (
+			SELECT started_at::date AS day, status, ports_scanned, open_ports, new_ports, closed_ports, hosts_up,
+			       ROW_NUMBER() OVER (PARTITION BY started_at::date, target_id, status
+			                          ORDER BY started_at DESC, id DESC) AS recency
+			FROM scan_sessions
+			WHERE started_at < CURRENT_DATE AND status != 'running'
+			  AND ($1::int = 0 OR started_at >= CURRENT_DATE - $1::int
+			       OR NOT EXISTS (SELECT 1 FROM daily_rollups dr WHERE dr.day = started_at::date))
+		) day_sessions
+		GROUP BY day
+		ON CONFLICT (day) DO UPDATE SET
+			sessions = EXCLUDED.sessions,
+			completed_sessions = EXCLUDED.completed_sessions,
+			ports_scanned = EXCLUDED.ports_scanned,
+			open_ports = EXCLUDED.open_ports,
+			new_ports = EXCLUDED.new_ports,
+			closed_ports = EXCLUDED.closed_ports,
+			hosts_up = EXCLUDED.hosts_up,
+			updated_at = NOW()
+	`, s.policy.RawDays)
+	if err != nil {
+		return fmt.Errorf("rolling up sessions: %w", err)
+	}
+
+	if days := s.policy.RawDays; days > 0 {
+		steps := []struct {
+			table string
+			query string
+		}{
+			// Events superseded before the cutoff; the event in force at the
+			// cutoff is kept so the state at any later time stays known
+			{"port_events", `
+				DELETE FROM port_events WHERE id IN (
+					SELECT pe.id FROM port_events pe
+					WHERE pe.occurred_at < CURRENT_DATE - $2::int
+					  AND EXISTS (
+						SELECT 1 FROM port_events n
+						WHERE n.ip_address = pe.ip_address AND n.port = pe.port AND n.protocol = pe.protocol
+						  AND (n.occurred_at, n.id) > (pe.occurred_at, pe.id)
+						  AND n.occurred_at < CURRENT_DATE - $2::int
+					  )
+					LIMIT $1
+				)`},
+			// No longer written since port_state replaced it
+			{"scan_results", `
+				DELETE FROM scan_results WHERE id IN (
+					SELECT id FROM scan_results WHERE scanned_at < CURRENT_DATE - $2::int LIMIT $1
+				)`},
+			{"host_discovery", `
+				DELETE FROM host_discovery WHERE id IN (
+					SELECT id FROM host_discovery WHERE discovered_at < CURRENT_DATE - $2::int LIMIT $1
+				)`},
+			// The latest resolution of each name is kept for change detection
+			{"dns_resolutions", `
+				DELETE FROM dns_resolutions WHERE id IN (
+					SELECT dr.id FROM dns_resolutions dr
+					WHERE dr.resolved_at < CURRENT_DATE - $2::int
+					  AND EXISTS (
+						SELECT 1 FROM dns_resolutions n
+						WHERE n.target_id = dr.target_id AND n.hostname = dr.hostname
+						  AND (n.resolved_at, n.id) > (dr.resolved_at, dr.id)
+					  )
+					LIMIT $1
+				)`},
+			{"adhoc_scans", `
+				DELETE FROM adhoc_scans WHERE id IN (
+					SELECT id FROM adhoc_scans
+					WHERE created_at < CURRENT_DATE - $2::int AND status != 'running'
+					LIMIT $1
+				)`},
+			// Sessions are already rolled up; one still holding a kept DNS
+			// resolution stays, since deleting it would cascade
+			{"scan_sessions", `
+				DELETE FROM scan_sessions WHERE id IN (
+					SELECT ss.id FROM scan_sessions ss
+					WHERE ss.started_at < CURRENT_DATE - $2::int AND ss.status != 'running'
+					  AND NOT EXISTS (SELECT 1 FROM dns_resolutions dr WHERE dr.session_id = ss.id)
+					LIMIT $1
+				)`},
+		}
+		for _, step := range steps {
+			if err := s.prune(pruned, step.table, step.query, days); err != nil {
+				return err
+			}
+		}
+	}
+
+	if days := s.policy.NotificationDays; days > 0 {
+		err := s.prune(pruned, "notifications", `
+			DELETE FROM notifications WHERE id IN (
+				SELECT id FROM notifications WHERE created_at < NOW() - $2 * INTERVAL '1 day' LIMIT $1
+			)`, days)
+		if err != nil {
+			return err
+		}
+	}
+
+	if days := s.policy.RollupDays; days > 0 {
+		err := s.prune(pruned, "daily_rollups", `
+			DELETE FROM daily_rollups WHERE day IN (
+				SELECT day FROM daily_rollups WHERE day < CURRENT_DATE - $2::int LIMIT $1
+			)`, days)
+		if err != nil {
+			return err
+		}
+		err = s.prune(pruned, "retention_runs", `
+			DELETE FROM retention_runs WHERE id IN (
+				SELECT id FROM retention_runs
+				WHERE started_at < CURRENT_DATE - $2::int AND status != 'running'
+				LIMIT $1
+			)`, days)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prune runs query, a DELETE of at most $1 rows, until it removes a partial
+// batch, adding the rows removed to pruned[table]. args follow the batch size.
+func (s *RetentionScheduler) prune(pruned map[string]int64, table, query string, args ...interface{}) error {
+	pruned[table] += 0
+	for {
+		select {
+		case <-s.stopCh:
+			return errRetentionStopped
+		default:
+		}
+
+		result, err := s.db.Exec(query, append([]interface{}{retentionBatch}, args...)...)
+		if err != nil {
+			return fmt.Errorf("pruning %s: %w", table, err)
+		}
+
+		removed, _ := result.RowsAffected()
+		pruned[table] += removed
+		if removed < retentionBatch {
+			return nil
+		}
+	}
+}
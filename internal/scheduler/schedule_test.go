@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"@every 30s",
+		"5x",
+		"@sometimes",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"0 0 * * someday",
+		"CRON_TZ=Nowhere/Zone 0 0 * * *",
+		"0 0 30 2 *",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"@every 6h", date(2024, 1, 1, 10, 2), date(2024, 1, 1, 16, 2)},
+		{"90m", date(2024, 1, 1, 10, 0), date(2024, 1, 1, 11, 30)},
+		{"CRON_TZ=UTC */5 * * * *", date(2024, 1, 1, 10, 2).Add(30 * time.Second), date(2024, 1, 1, 10, 5)},
+		// The next run is strictly after from
+		{"CRON_TZ=UTC */5 * * * *", date(2024, 1, 1, 10, 5), date(2024, 1, 1, 10, 10)},
+		{"CRON_TZ=UTC 15,45 * * * *", date(2024, 1, 1, 10, 20), date(2024, 1, 1, 10, 45)},
+		{"CRON_TZ=UTC 0 2 * * *", date(2024, 1, 1, 3, 0), date(2024, 1, 2, 2, 0)},
+		{"CRON_TZ=UTC @daily", date(2024, 1, 31, 12, 0), date(2024, 2, 1, 0, 0)},
+		// Friday evening to Monday morning
+		{"CRON_TZ=UTC 0 9 * * mon-fri", date(2024, 1, 5, 10, 0), date(2024, 1, 8, 9, 0)},
+		// 7 is Sunday too
+		{"CRON_TZ=UTC 0 0 * * 7", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 0, 0)},
+		// With both restricted, either the day of month or the weekday matches
+		{"CRON_TZ=UTC 0 0 13 * fri", date(2024, 1, 1, 0, 0), date(2024, 1, 5, 0, 0)},
+		{"CRON_TZ=UTC 0 0 1 jan *", date(2024, 6, 1, 0, 0), date(2025, 1, 1, 0, 0)},
+		{"CRON_TZ=UTC 0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		// 02:00 in New York is 07:00 UTC in winter and 06:00 UTC in summer
+		{"CRON_TZ=America/New_York 0 2 * * *", date(2024, 1, 1, 0, 0), date(2024, 1, 1, 7, 0)},
+		{"CRON_TZ=America/New_York 0 2 * * *", date(2024, 7, 1, 0, 0), date(2024, 7, 1, 6, 0)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("ParseSchedule(%q).Next(%v) = %v, want %v", tt.spec, tt.from, got.UTC(), tt.want)
+		}
+	}
+}
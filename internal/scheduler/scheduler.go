@@ -1,9 +1,14 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,71 +17,152 @@ import (
 
 type Scheduler struct {
 	db          *sql.DB
-	interval    time.Duration
+	interval    time.Duration // schedule of targets without their own
 	stopCh      chan struct{}
 	wg          sync.WaitGroup
-	scanning    bool
 	scanningMux sync.RWMutex
 	manualScan  chan struct{}
+
+	// certExpiryDays is how far ahead of expiry a certificate is reported
+	certExpiryDays int
+
+	// tcpEngine is the engine used for TCP ports of targets without their own
+	tcpEngine string
+
+	// scanOptions tune every engine the scheduler runs
+	scanOptions scanner.Options
+
+	// ctx is cancelled by Stop so in-flight scans and verifications end promptly
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// running maps the ID of each scan session in progress to the function
+	// that cancels it, and inFlight holds the targets dispatch has started a
+	// scan of; both guarded by scanningMux
+	running  map[int]context.CancelCauseFunc
+	inFlight map[int]bool
+
+	// sessionSlots limits how many scan sessions run at once
+	sessionSlots chan struct{}
+
+	// maxScanDuration is the time budget of a scan session; zero means no limit
+	maxScanDuration time.Duration
+
+	// progress tracks the running scans for the status endpoints
+	progress progressTracker
+
+	// adhocSlots limits how many ad-hoc scans run in the background
+	adhocSlots chan struct{}
+
+	// verifyDelay is how long after a port is seen closing it is re-checked,
+	// and verifyRetries how many more checks are made while it doesn't answer
+	verifyDelay   time.Duration
+	verifyRetries int
+
+	// A port that changes state flapThreshold times within flapWindow is
+	// flapping and its per-transition alerts are suppressed
+	flapThreshold int
+	flapWindow    time.Duration
+
+	// deferred maps targets held back by a blackout window to that window's
+	// ID, so each deferral is reported once; only used on the run goroutine
+	deferred map[int]int
 }
 
-type portVerification struct {
-	targetID  int
-	ip        string
-	port      int
-	timestamp time.Time
+// Reasons a scan session is cancelled, stored in scan_sessions.cancel_reason
+var (
+	ErrSchedulerStopped = errors.New("scheduler stopped")
+	ErrScanTimeBudget   = errors.New("scan exceeded its time budget")
+)
+
+// CancelledByUser is the cause of a scan cancelled through the API
+type CancelledByUser struct {
+	User string
+}
+
+func (e *CancelledByUser) Error() string {
+	if e.User == "" {
+		return "cancelled by user"
+	}
+	return "cancelled by " + e.User
 }
 
 func NewScheduler(db *sql.DB, interval time.Duration) *Scheduler {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
 	return &Scheduler{
-		db:         db,
-		interval:   interval,
-		stopCh:     make(chan struct{}),
-		manualScan: make(chan struct{}, 1),
+		db:              db,
+		interval:        interval,
+		stopCh:          make(chan struct{}),
+		manualScan:      make(chan struct{}, 1),
+		certExpiryDays:  getEnvInt("CERT_EXPIRY_WARNING_DAYS", 30),
+		tcpEngine:       tcpEngineFromEnv(),
+		scanOptions:     scanOptionsFromEnv(),
+		ctx:             ctx,
+		cancel:          cancel,
+		running:         make(map[int]context.CancelCauseFunc),
+		inFlight:        make(map[int]bool),
+		sessionSlots:    make(chan struct{}, getEnvIntAtLeast("SCAN_MAX_SESSIONS", 3, 1)),
+		maxScanDuration: time.Duration(getEnvInt("SCAN_MAX_DURATION_MINUTES", 0)) * time.Minute,
+		adhocSlots:      make(chan struct{}, maxAdhocJobs),
+		deferred:        make(map[int]int),
+		verifyDelay:     time.Duration(getEnvIntAtLeast("PORT_VERIFY_DELAY_SECONDS", 60, 1)) * time.Second,
+		verifyRetries:   getEnvIntAtLeast("PORT_VERIFY_RETRIES", 2, 0),
+		flapThreshold:   getEnvIntAtLeast("FLAP_THRESHOLD", 4, 2),
+		flapWindow:      time.Duration(getEnvIntAtLeast("FLAP_WINDOW_MINUTES", 120, 1)) * time.Minute,
 	}
 }
 
+// scheduleCheckInterval is how often the scheduler looks for targets that
+// are due for a scan
+const scheduleCheckInterval = 1 * time.Minute
+
 // Start begins the scheduled scanning
 func (s *Scheduler) Start() {
-	s.wg.Add(1)
+	s.wg.Add(2)
 	go s.run()
+	go s.runVerifications()
 	log.Printf("Scheduler started with interval: %v", s.interval)
 }
 
-// Stop gracefully stops the scheduler
+// Stop gracefully stops the scheduler, cancelling any scans in progress
 func (s *Scheduler) Stop() {
+	s.cancel(ErrSchedulerStopped)
 	close(s.stopCh)
 	s.wg.Wait()
 	log.Println("Scheduler stopped")
 }
 
+// run checks for due targets every scheduleCheckInterval and starts their
+// scans, which run in the background, so a target that comes due while
+// another is being scanned doesn't wait for it to finish
 func (s *Scheduler) run() {
 	defer s.wg.Done()
 
-	// Run first scan immediately
-	s.performScan()
+	// Targets that have never been scanned are due straight away
+	s.dispatch(false)
 
-	ticker := time.NewTicker(s.interval)
+	ticker := time.NewTicker(scheduleCheckInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			s.performScan()
+			s.dispatch(false)
 		case <-s.manualScan:
 			log.Println("Manual scan triggered")
-			s.performScan()
+			s.dispatch(true)
 		case <-s.stopCh:
 			return
 		}
 	}
 }
 
-// IsScanning returns true if a scan is currently in progress
+// IsScanning returns true if any scan is currently in progress
 func (s *Scheduler) IsScanning() bool {
 	s.scanningMux.RLock()
 	defer s.scanningMux.RUnlock()
-	return s.scanning
+	return len(s.running) > 0
 }
 
 // TriggerScan manually triggers a scan (non-blocking)
@@ -90,67 +176,156 @@ func (s *Scheduler) TriggerScan() bool {
 	}
 }
 
-func (s *Scheduler) performScan() {
-	// Set scanning flag
+// CancelScan stops every scan in progress on behalf of user. It returns the
+// session IDs of the cancelled scans, in order, or none if no scan is running.
+func (s *Scheduler) CancelScan(user string) []int {
 	s.scanningMux.Lock()
-	s.scanning = true
-	s.scanningMux.Unlock()
+	defer s.scanningMux.Unlock()
 
-	defer func() {
-		s.scanningMux.Lock()
-		s.scanning = false
-		s.scanningMux.Unlock()
-	}()
+	sessionIDs := []int{}
+	for sessionID, cancel := range s.running {
+		cancel(&CancelledByUser{User: user})
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	sort.Ints(sessionIDs)
+	return sessionIDs
+}
 
-	log.Println("Starting scheduled scan...")
+// CancelSession stops the scan of a specific session on behalf of user. It
+// returns false if that session isn't running.
+func (s *Scheduler) CancelSession(sessionID int, user string) bool {
+	s.scanningMux.Lock()
+	defer s.scanningMux.Unlock()
 
-	// Create scan session
-	var sessionID int
-	err := s.db.QueryRow(`
-		INSERT INTO scan_sessions (started_at, status)
-		VALUES (NOW(), 'running')
-		RETURNING id
-	`).Scan(&sessionID)
-	if err != nil {
-		log.Printf("Failed to create scan session: %v", err)
+	cancel, ok := s.running[sessionID]
+	if !ok {
+		return false
+	}
+	cancel(&CancelledByUser{User: user})
+	return true
+}
+
+// dispatch starts a scan session for each target that is due, or for every
+// enabled target when all is set. Each target is scanned in its own session
+// and at most cap(sessionSlots) run at once, so a long scan of a large range
+// doesn't hold up targets scheduled more often. A target still being scanned
+// isn't started again. Scheduled targets that find no free slot stay due and
+// are started by a later check; a manual run waits for slots instead, since
+// its targets may not be due.
+func (s *Scheduler) dispatch(all bool) {
+	if s.ctx.Err() != nil {
 		return
 	}
 
-	// Get all enabled targets
-	rows, err := s.db.Query(`
-		SELECT id, target
-		FROM scan_targets
-		WHERE enabled = true
-	`)
+	// Ports settle with time, so this runs on every check, due targets or not
+	s.settleFlaps()
+
+	due, err := s.dueTargets(all)
 	if err != nil {
 		log.Printf("Failed to fetch targets: %v", err)
-		s.markSessionFailed(sessionID)
 		return
 	}
-	defer rows.Close()
 
-	type target struct {
-		id     int
-		target string
+	idle := []scanTarget{}
+	s.scanningMux.RLock()
+	for _, t := range due {
+		if !s.inFlight[t.id] {
+			idle = append(idle, t)
+		}
 	}
+	s.scanningMux.RUnlock()
 
-	targets := []target{}
-	for rows.Next() {
-		var t target
-		if err := rows.Scan(&t.id, &t.target); err != nil {
-			log.Printf("Failed to scan target row: %v", err)
-			continue
+	targets, skipped := s.applyBlackouts(idle)
+	if len(skipped) > 0 {
+		s.recordSkippedRun(skipped)
+	}
+	if len(targets) == 0 {
+		if all && len(due) == 0 {
+			log.Println("No enabled targets found")
 		}
-		targets = append(targets, t)
+		return
 	}
 
-	if len(targets) == 0 {
-		log.Println("No enabled targets found")
-		s.markSessionCompleted(sessionID, 0, 0)
+	for i, t := range targets {
+		if all {
+			select {
+			case s.sessionSlots <- struct{}{}:
+			case <-s.stopCh:
+				return
+			}
+		} else {
+			select {
+			case s.sessionSlots <- struct{}{}:
+			default:
+				log.Printf("All %d scan slots busy, %d due targets wait for the next check", cap(s.sessionSlots), len(targets)-i)
+				return
+			}
+		}
+
+		s.scanningMux.Lock()
+		s.inFlight[t.id] = true
+		s.scanningMux.Unlock()
+
+		// The run counts from its start, even if it is cancelled part way
+		s.markTargetsScheduled([]scanTarget{t}, time.Now())
+
+		s.wg.Add(1)
+		go func(t scanTarget) {
+			defer s.wg.Done()
+			defer func() { <-s.sessionSlots }()
+			defer func() {
+				s.scanningMux.Lock()
+				delete(s.inFlight, t.id)
+				s.scanningMux.Unlock()
+			}()
+
+			s.performScan(s.ctx, t)
+		}(t)
+	}
+}
+
+// performScan runs a scan session over target t
+func (s *Scheduler) performScan(parent context.Context, t scanTarget) {
+	if parent.Err() != nil {
 		return
 	}
 
-	log.Printf("Scanning %d targets...", len(targets))
+	// Every dial of the session derives from ctx, so cancelling it stops
+	// in-flight work; the time budget, if any, cancels it too
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	if s.maxScanDuration > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, s.maxScanDuration, ErrScanTimeBudget)
+		defer cancelTimeout()
+	}
+
+	// Create scan session
+	var sessionID int
+	err := s.db.QueryRow(`
+		INSERT INTO scan_sessions (started_at, status, target_id)
+		VALUES (NOW(), 'running', $1)
+		RETURNING id
+	`, t.id).Scan(&sessionID)
+	if err != nil {
+		log.Printf("Failed to create scan session: %v", err)
+		return
+	}
+
+	s.scanningMux.Lock()
+	s.running[sessionID] = cancel
+	s.scanningMux.Unlock()
+
+	defer func() {
+		s.scanningMux.Lock()
+		delete(s.running, sessionID)
+		s.scanningMux.Unlock()
+	}()
+
+	log.Printf("Starting scheduled scan of %s (session %d)...", t.target, sessionID)
+
+	s.progress.start(sessionID, 1, t.parsed.Count())
+	defer s.progress.finish(sessionID)
 
 	totalTargets := 0
 	totalPorts := 0
@@ -158,93 +333,188 @@ func (s *Scheduler) performScan() {
 	// Use a mutex to protect counters
 	var mu sync.Mutex
 
-	// Scan each target
-	for _, t := range targets {
-		ips, err := scanner.ParseCIDR(t.target)
-		if err != nil {
-			log.Printf("Failed to parse target %s: %v", t.target, err)
-			continue
+	parsed := t.parsed
+	countBefore := parsed.Count()
+
+	// Host names are resolved afresh on every scan
+	if len(parsed.Hostnames()) > 0 {
+		resolutions := parsed.Resolve(ctx)
+		if ctx.Err() == nil {
+			s.recordResolutions(sessionID, t.id, resolutions)
 		}
+	}
+	s.progress.setTarget(sessionID, t.target, countBefore, parsed.Count())
+
+	tcpScanner := s.engineFor(t.engine)
+	udpScanner, _ := scanner.Lookup(scanner.EngineUDP)
+
+	log.Printf("Scanning target %s (%d IPs, %d ports, %s engine)...", t.target, parsed.Count(), len(t.ports), tcpScanner.Name())
+
+	// Use a worker pool to scan IPs in parallel
+	// Addresses are streamed from the iterator so memory stays constant
+	// regardless of how large the target is
+	numWorkers := 20 // Scan 20 IPs concurrently
+	ipChan := make(chan string, numWorkers)
+	go func() {
+		defer close(ipChan)
+		addresses := parsed.Addresses()
+		for ip, ok := addresses.Next(); ok; ip, ok = addresses.Next() {
+			select {
+			case ipChan <- ip:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Create a wait group for workers
+	var wg sync.WaitGroup
+
+	// Start worker goroutines
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range ipChan {
+				if ctx.Err() != nil {
+					continue
+				}
 
-		log.Printf("Scanning target %s (%d IPs)...", t.target, len(ips))
+				// Skip hosts that don't answer the discovery probe. The
+				// ports last seen open on them are stored as unreachable, so
+				// their closure is verified like any other.
+				hostDown := false
+				if t.discovery != scanner.DiscoveryNone {
+					discovery := scanner.DiscoverHost(ctx, ip, t.discovery, discoveryTimeout)
+					if ctx.Err() != nil {
+						continue
+					}
+					s.recordDiscovery(sessionID, t.id, discovery)
+					hostDown = !discovery.Up
+				}
 
-		// Use a worker pool to scan IPs in parallel
-		// Create a channel for IP addresses
-		ipChan := make(chan string, len(ips))
-		for _, ip := range ips {
-			ipChan <- ip
-		}
-		close(ipChan)
-
-		// Create a wait group for workers
-		var wg sync.WaitGroup
-		numWorkers := 20 // Scan 20 IPs concurrently
-
-		// Start worker goroutines
-		for i := 0; i < numWorkers; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for ip := range ipChan {
-					// Use parallel port scanning
-					results := scanner.ScanIPParallel(ip, scanner.CommonPorts, 2*time.Second)
-
-					// Store results in database
-					for _, result := range results {
-						// Check for previous scan result to detect changes
-						var previousStatus string
-						err := s.db.QueryRow(`
-							SELECT status FROM scan_results
-							WHERE target_id = $1 AND ip_address = $2 AND port = $3
-							ORDER BY scanned_at DESC
-							LIMIT 1
-						`, t.id, result.IP, result.Port).Scan(&previousStatus)
-
-						// Store the new scan result
-						_, err = s.db.Exec(`
-							INSERT INTO scan_results (target_id, ip_address, port, status, response_time_ms, scanned_at)
-							VALUES ($1, $2, $3, $4, $5, NOW())
-						`, t.id, result.IP, result.Port, result.Status, result.ResponseTimeMs)
-
-						if err != nil {
-							log.Printf("Failed to store scan result: %v", err)
+				var results []scanner.PortScanResult
+				if hostDown {
+					results = s.unreachablePorts(t, ip, tcpScanner.Name())
+					if len(results) == 0 {
+						s.progress.ipDone(sessionID, 0, 0)
+						continue
+					}
+				} else {
+					// A cancelled scan still returns the ports it finished,
+					// and those are stored like any other result
+					var err error
+					results, err = tcpScanner.ScanIP(ctx, ip, t.ports, s.scanOptions)
+					if err != nil && ctx.Err() == nil {
+						log.Printf("Failed to scan %s: %v", ip, err)
+						continue
+					}
+					if t.scanUDP && ctx.Err() == nil {
+						udpResults, err := udpScanner.ScanIP(ctx, ip, t.udpPorts, s.scanOptions)
+						if err != nil && ctx.Err() == nil {
+							log.Printf("Failed to scan UDP ports of %s: %v", ip, err)
 						} else {
-							mu.Lock()
-							totalPorts++
-							mu.Unlock()
-
-							// Create notification if port status changed
-							if previousStatus == "closed" && result.Status == "open" {
-								// Port opened - notify immediately
-								s.createNotification(t.id, result.IP, result.Port, "new_port")
-							} else if previousStatus == "open" && result.Status == "closed" {
-								// Port closed - schedule verification in 1 minute
-								s.schedulePortVerification(t.id, result.IP, result.Port)
+							results = append(results, udpResults...)
+						}
+					}
+
+					// Fingerprint whatever answered on the open ports
+					scanner.DetectServices(ctx, results, s.scanOptions.Timeout)
+				}
+
+				// Store results in database
+				stored, open := 0, 0
+				for _, result := range results {
+					// Store the result, keeping the previous status to detect changes
+					previousStatus, err := s.recordPortResult(sessionID, t.id, result)
+					if err != nil {
+						log.Printf("Failed to store scan result: %v", err)
+					} else {
+						mu.Lock()
+						totalPorts++
+						mu.Unlock()
+
+						stored++
+						if result.Status == scanner.StatusOpen {
+							open++
+						}
+
+						// Create notification if the port became reachable or stopped
+						// being reachable. Moves between closed, filtered and
+						// unreachable are firewall or routing noise, not port events,
+						// and neither is a UDP probe that got no reply. A port first
+						// seen, or only seen without a reply, has nothing to compare.
+						// Ports that keep flipping only raise a single flapping alert.
+						change := portChange(previousStatus, result.Status)
+						known := previousStatus != "" && scanner.Determinate(previousStatus)
+						if change == "new" && known {
+							// Port opened - notify immediately
+							if !s.recordTransition(sessionID, t.id, result.IP, result.Port, result.Protocol, previousStatus) {
+								s.createNotification(sessionID, t.id, result.IP, result.Port, result.Protocol, "new_port")
+							}
+						} else if change == "closed" {
+							// Port closed - queue a verification before notifying
+							if !s.recordTransition(sessionID, t.id, result.IP, result.Port, result.Protocol, previousStatus) {
+								s.schedulePortVerification(sessionID, t.id, result.IP, result.Port, result.Protocol, result.Engine)
 							}
 						}
 					}
 
+					if result.TLS != nil {
+						s.recordCertificate(sessionID, t.id, result.IP, result.Port, result.TLS)
+					}
+				}
+
+				// Cache host names for IPs that expose something
+				hasOpen, hasTLS := false, false
+				for _, result := range results {
+					hasOpen = hasOpen || result.Status == scanner.StatusOpen
+					hasTLS = hasTLS || result.TLS != nil
+				}
+				if hasOpen {
+					s.enrichHost(ctx, ip)
+				}
+				if hasTLS {
+					s.refreshCertificateNames(ip)
+				}
+
+				if !hostDown {
 					mu.Lock()
 					totalTargets++
 					mu.Unlock()
 				}
-			}()
-		}
 
-		// Wait for all workers to complete
-		wg.Wait()
+				s.progress.ipDone(sessionID, stored, open)
+			}
+		}()
+	}
+
+	// Wait for all workers to complete
+	wg.Wait()
+	s.progress.targetDone(sessionID)
+
+	if ctx.Err() != nil {
+		cause := context.Cause(ctx)
+		s.markSessionCancelled(sessionID, totalTargets, totalPorts, cause)
+		log.Printf("Scan of %s cancelled (%v): %d IPs scanned, %d ports checked", t.target, cause, totalTargets, totalPorts)
+		return
 	}
 
 	// Mark session as completed
 	s.markSessionCompleted(sessionID, totalTargets, totalPorts)
 
-	log.Printf("Scan completed: %d IPs scanned, %d ports checked", totalTargets, totalPorts)
+	log.Printf("Scan of %s completed: %d IPs scanned, %d ports checked", t.target, totalTargets, totalPorts)
 }
 
 func (s *Scheduler) markSessionCompleted(sessionID, targets, ports int) {
 	_, err := s.db.Exec(`
 		UPDATE scan_sessions
-		SET completed_at = NOW(), targets_scanned = $1, ports_scanned = $2, status = 'completed'
+		SET completed_at = NOW(), targets_scanned = $1, ports_scanned = $2, status = 'completed',
+		    hosts_up = (SELECT COUNT(*) FROM host_discovery WHERE session_id = $3 AND is_up),
+		    hosts_down = (SELECT COUNT(*) FROM host_discovery WHERE session_id = $3 AND NOT is_up),
+		    open_ports = (SELECT COUNT(*) FROM port_events WHERE last_session_id = $3 AND status = 'open'),
+		    new_ports = (SELECT COUNT(*) FROM port_events WHERE session_id = $3 AND change = 'new'),
+		    closed_ports = (SELECT COUNT(*) FROM port_events WHERE session_id = $3 AND change = 'closed')
 		WHERE id = $3
 	`, targets, ports, sessionID)
 	if err != nil {
@@ -252,58 +522,58 @@ func (s *Scheduler) markSessionCompleted(sessionID, targets, ports int) {
 	}
 }
 
-func (s *Scheduler) markSessionFailed(sessionID int) {
+// markSessionCancelled records how far a cancelled session got, why it
+// stopped and, when cancelled through the API, who cancelled it. Results
+// stored before the cancellation are kept.
+func (s *Scheduler) markSessionCancelled(sessionID, targets, ports int, cause error) {
+	var cancelledBy string
+	var byUser *CancelledByUser
+	if errors.As(cause, &byUser) {
+		cancelledBy = byUser.User
+	}
+
 	_, err := s.db.Exec(`
 		UPDATE scan_sessions
-		SET completed_at = NOW(), status = 'failed'
-		WHERE id = $1
-	`, sessionID)
+		SET completed_at = NOW(), targets_scanned = $1, ports_scanned = $2, status = 'cancelled',
+		    cancel_reason = $3, cancelled_by = NULLIF($4, ''),
+		    hosts_up = (SELECT COUNT(*) FROM host_discovery WHERE session_id = $5 AND is_up),
+		    hosts_down = (SELECT COUNT(*) FROM host_discovery WHERE session_id = $5 AND NOT is_up),
+		    open_ports = (SELECT COUNT(*) FROM port_events WHERE last_session_id = $5 AND status = 'open'),
+		    new_ports = (SELECT COUNT(*) FROM port_events WHERE session_id = $5 AND change = 'new'),
+		    closed_ports = (SELECT COUNT(*) FROM port_events WHERE session_id = $5 AND change = 'closed')
+		WHERE id = $5
+	`, targets, ports, cause.Error(), cancelledBy, sessionID)
 	if err != nil {
 		log.Printf("Failed to update scan session: %v", err)
 	}
 }
 
-// schedulePortVerification schedules a re-check of a specific port after 1 minute
-func (s *Scheduler) schedulePortVerification(targetID int, ip string, port int) {
-	log.Printf("Scheduling verification for %s:%d in 1 minute", ip, port)
-
-	time.AfterFunc(1*time.Minute, func() {
-		log.Printf("Verifying port closure for %s:%d", ip, port)
-
-		// Re-scan just this specific port
-		result := scanner.ScanPort(ip, port, 2*time.Second)
-
-		if result.Status == "closed" {
-			// Port is still closed after verification, create notification
-			log.Printf("Verification confirmed: %s:%d is still closed", ip, port)
-			s.createNotification(targetID, ip, port, "port_closed")
-		} else {
-			// Port reopened, log but don't notify
-			log.Printf("Verification failed: %s:%d reopened, not recording closure", ip, port)
-		}
-	})
-}
-
-func (s *Scheduler) createNotification(targetID int, ip string, port int, notificationType string) {
+func (s *Scheduler) createNotification(sessionID, targetID int, ip string, port int, protocol string, notificationType string) {
 	var title, message, severity string
 
 	switch notificationType {
 	case "new_port":
 		title = "New Open Port Detected"
-		message = fmt.Sprintf("Port %d is now open on %s", port, ip)
+		message = fmt.Sprintf("Port %d/%s is now open on %s", port, protocol, ip)
 		severity = "warning"
 	case "port_closed":
 		title = "Port Closed"
-		message = fmt.Sprintf("Port %d is now closed on %s (verified)", port, ip)
+		message = fmt.Sprintf("Port %d/%s is now closed on %s (verified)", port, protocol, ip)
 		severity = "info"
 	default:
 		return
 	}
 
+	s.insertNotification(sessionID, targetID, ip, port, notificationType, title, message, severity)
+}
+
+func (s *Scheduler) insertNotification(sessionID, targetID int, ip string, port int, notificationType, title, message, severity string) {
+	// Notifications that aren't about a single port leave ip and port empty,
+	// and those raised outside a scan session leave the session empty
 	_, err := s.db.Exec(`
-		INSERT INTO notifications (type, title, message, severity, ip_address, port, target_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, notificationType, title, message, severity, ip, port, targetID)
+		INSERT INTO notifications (type, title, message, severity, ip_address, port, target_id, session_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::inet, NULLIF($6, 0), $7, NULLIF($8, 0))
+	`, notificationType, title, message, severity, ip, port, targetID, sessionID)
 
 	if err != nil {
 		log.Printf("Failed to create notification: %v", err)
@@ -311,3 +581,72 @@ func (s *Scheduler) createNotification(targetID int, ip string, port int, notifi
 		log.Printf("Created notification: %s for %s:%d", notificationType, ip, port)
 	}
 }
+
+// getEnvInt reads an integer setting from the environment, falling back to
+// def when it is unset or malformed
+func getEnvInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %d", name, value, def)
+		return def
+	}
+	return n
+}
+
+// getEnvIntAtLeast is getEnvInt for settings with a lower bound, falling
+// back to def when the value is below lowest
+func getEnvIntAtLeast(name string, def, lowest int) int {
+	n := getEnvInt(name, def)
+	if n < lowest {
+		log.Printf("Invalid value for %s (%d, must be at least %d), using default %d", name, n, lowest, def)
+		return def
+	}
+	return n
+}
+
+// DefaultEngine returns the engine used for targets without their own
+func (s *Scheduler) DefaultEngine() string {
+	return s.tcpEngine
+}
+
+// engineFor returns the TCP engine for a target, falling back to the default
+// when the target has none or names one that isn't registered
+func (s *Scheduler) engineFor(name string) scanner.Scanner {
+	if name == "" {
+		name = s.tcpEngine
+	}
+
+	engine, err := scanner.Lookup(name)
+	if err != nil {
+		log.Printf("%v, using %s", err, s.tcpEngine)
+		engine, _ = scanner.Lookup(s.tcpEngine)
+	}
+	return engine
+}
+
+// tcpEngineFromEnv reads TCP_SCAN_ENGINE, defaulting to connect scans
+func tcpEngineFromEnv() string {
+	engine := os.Getenv("TCP_SCAN_ENGINE")
+	if engine == "" {
+		return scanner.EngineConnect
+	}
+	if !scanner.IsTCPEngine(engine) {
+		log.Printf("Unknown TCP_SCAN_ENGINE %q, using connect scans", engine)
+		return scanner.EngineConnect
+	}
+	return engine
+}
+
+// scanOptionsFromEnv reads the engine options, defaulting to scanner.DefaultOptions
+func scanOptionsFromEnv() scanner.Options {
+	opts := scanner.DefaultOptions()
+	opts.Timeout = time.Duration(getEnvIntAtLeast("SCAN_TIMEOUT_MS", int(opts.Timeout.Milliseconds()), 1)) * time.Millisecond
+	opts.Retries = getEnvIntAtLeast("SCAN_RETRIES", opts.Retries, 0)
+	opts.Concurrency = getEnvIntAtLeast("SCAN_PORT_CONCURRENCY", opts.Concurrency, 1)
+	return opts
+}
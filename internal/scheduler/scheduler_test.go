@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ip-scanner/internal/scanner"
+)
+
+// fakeStore stands in for PostgreSQL in scheduler tests. It keeps the
+// current state of each port and records the events, notifications and
+// verifications a scan writes. Statements are recognised by their opening
+// words; anything else succeeds without rows.
+type fakeStore struct {
+	mu            sync.Mutex
+	nextID        int64
+	state         map[string]string // ip:port/protocol -> status
+	events        []string
+	notifications []string
+	verifications []string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{state: make(map[string]string)}
+}
+
+func portKey(ip, port, protocol driver.Value) string {
+	return fmt.Sprintf("%v:%v/%v", ip, port, protocol)
+}
+
+// reset clears what was recorded, keeping the port state
+func (f *fakeStore) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events, f.notifications, f.verifications = nil, nil, nil
+}
+
+// handle runs one statement, returning the columns and rows of a query
+func (f *fakeStore) handle(query string, args []driver.Value) ([]string, [][]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO scan_sessions"):
+		f.nextID++
+		return []string{"id"}, [][]driver.Value{{f.nextID}}
+
+	case strings.HasPrefix(query, "SELECT status, event_id FROM port_state"):
+		status, ok := f.state[portKey(args[0], args[1], args[2])]
+		if !ok {
+			return []string{"status", "event_id"}, nil
+		}
+		return []string{"status", "event_id"}, [][]driver.Value{{status, int64(1)}}
+
+	case strings.HasPrefix(query, "INSERT INTO port_events"):
+		// session, target, ip, port, protocol, previous status, status, change
+		f.events = append(f.events, fmt.Sprintf("%s %v>%v %v", portKey(args[2], args[3], args[4]), args[5], args[6], args[7]))
+		f.nextID++
+		return []string{"id"}, [][]driver.Value{{f.nextID}}
+
+	case strings.HasPrefix(query, "INSERT INTO port_state"):
+		f.state[portKey(args[0], args[1], args[2])] = args[6].(string)
+
+	case strings.HasPrefix(query, "INSERT INTO port_flaps"):
+		// One transition in the window, not flapping
+		return []string{"transitions", "flapping"}, [][]driver.Value{{int64(1), false}}
+
+	case strings.HasPrefix(query, "INSERT INTO notifications"):
+		// type, title, message, severity, ip, port
+		f.notifications = append(f.notifications, fmt.Sprintf("%v %v:%v", args[0], args[4], args[5]))
+
+	case strings.HasPrefix(query, "INSERT INTO port_verifications"):
+		// session, target, ip, port, protocol
+		f.verifications = append(f.verifications, portKey(args[2], args[3], args[4]))
+
+	case strings.HasPrefix(query, "SELECT ptr_resolved_at FROM hosts"):
+		// Freshly resolved, so no reverse lookups are made
+		return []string{"ptr_resolved_at"}, [][]driver.Value{{time.Now()}}
+	}
+
+	return nil, nil
+}
+
+// fakeConnector opens connections that hand every statement to a fakeStore
+type fakeConnector struct{ store *fakeStore }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{store: c.store}, nil
+}
+func (c fakeConnector) Driver() driver.Driver { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("open the fake database with sql.OpenDB")
+}
+
+type fakeConn struct{ store *fakeStore }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c fakeConn) Close() error { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.store.handle(query, values(args))
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	columns, rows := c.store.handle(query, values(args))
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestPerformScanChangeDetection(t *testing.T) {
+	store := newFakeStore()
+	db := sql.OpenDB(fakeConnector{store: store})
+	defer db.Close()
+
+	s := NewScheduler(db, time.Hour)
+	defer s.cancel(ErrSchedulerStopped)
+	// Open ports are fingerprinted, which against TEST-NET addresses only
+	// waits out the timeout
+	s.scanOptions.Timeout = 50 * time.Millisecond
+
+	parsed, err := scanner.ParseTarget("192.0.2.0/30")
+	if err != nil {
+		t.Fatalf("ParseTarget error: %v", err)
+	}
+	target := scanTarget{
+		id:        1,
+		target:    "192.0.2.0/30",
+		discovery: scanner.DiscoveryNone,
+		engine:    scanner.EngineMock,
+		ports:     []int{22, 80},
+		parsed:    parsed,
+	}
+
+	scans := []struct {
+		name          string
+		open          map[string][]int
+		events        []string
+		notifications []string
+		verifications []string
+	}{
+		{
+			// Ports seen for the first time have nothing to compare
+			name: "first sight",
+			open: map[string][]int{"192.0.2.1": {22}},
+			events: []string{
+				"192.0.2.1:22/tcp >open new",
+				"192.0.2.1:80/tcp >closed ",
+				"192.0.2.2:22/tcp >closed ",
+				"192.0.2.2:80/tcp >closed ",
+			},
+		},
+		{
+			name: "unchanged",
+			open: map[string][]int{"192.0.2.1": {22}},
+		},
+		{
+			// An opened port is notified at once, a closed one verified first
+			name: "opened and closed",
+			open: map[string][]int{"192.0.2.2": {80}},
+			events: []string{
+				"192.0.2.1:22/tcp open>closed closed",
+				"192.0.2.2:80/tcp closed>open new",
+			},
+			notifications: []string{"new_port 192.0.2.2:80"},
+			verifications: []string{"192.0.2.1:22/tcp"},
+		},
+	}
+
+	for _, scan := range scans {
+		store.reset()
+		scanner.Register(&scanner.MockScanner{OpenPorts: scan.open})
+
+		s.performScan(context.Background(), target)
+
+		sort.Strings(store.events)
+		sort.Strings(store.notifications)
+		sort.Strings(store.verifications)
+		if !reflect.DeepEqual(store.events, scan.events) {
+			t.Errorf("%s: events = %q, want %q", scan.name, store.events, scan.events)
+		}
+		if !reflect.DeepEqual(store.notifications, scan.notifications) {
+			t.Errorf("%s: notifications = %q, want %q", scan.name, store.notifications, scan.notifications)
+		}
+		if !reflect.DeepEqual(store.verifications, scan.verifications) {
+			t.Errorf("%s: verifications = %q, want %q", scan.name, store.verifications, scan.verifications)
+		}
+	}
+
+	if s.IsScanning() {
+		t.Error("IsScanning() = true after the scans finished")
+	}
+}
@@ -0,0 +1,163 @@
+package scheduler
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"ip-scanner/internal/scanner"
+)
+
+// scanTarget is an enabled target as the scheduler scans it
+type scanTarget struct {
+	id        int
+	target    string
+	scanUDP   bool
+	discovery string
+	engine    string
+	ports     []int
+	udpPorts  []int
+	parsed    *scanner.Target
+	schedule  Schedule
+}
+
+// dueTargets returns the enabled targets whose schedule says they are due,
+// or every enabled target when all is set. The planned next run of targets
+// that aren't due is kept up to date for ListTargets.
+func (s *Scheduler) dueTargets(all bool) ([]scanTarget, error) {
+	rows, err := s.db.Query(`
+		SELECT st.id, st.target, st.scan_udp, st.discovery_method, COALESCE(st.scan_engine, ''),
+		       COALESCE(st.schedule, ''), st.last_scan_at, st.next_scan_at,
+		       COALESCE(pp.ports, ''), COALESCE(pp.udp_ports, '')
+		FROM scan_targets st
+		LEFT JOIN port_profiles pp ON st.port_profile_id = pp.id
+		WHERE st.enabled = true
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type plan struct {
+		id   int
+		next time.Time
+	}
+	var plans []plan
+
+	now := time.Now()
+	targets := []scanTarget{}
+	for rows.Next() {
+		var t scanTarget
+		var scheduleSpec, portSpec, udpPortSpec string
+		var lastScanAt, nextScanAt sql.NullTime
+		err := rows.Scan(
+			&t.id, &t.target, &t.scanUDP, &t.discovery, &t.engine,
+			&scheduleSpec, &lastScanAt, &nextScanAt, &portSpec, &udpPortSpec,
+		)
+		if err != nil {
+			log.Printf("Failed to scan target row: %v", err)
+			continue
+		}
+
+		t.schedule = s.scheduleFor(t.target, scheduleSpec)
+
+		// Targets that have never been scanned are due straight away
+		if lastScanAt.Valid {
+			next := t.schedule.Next(lastScanAt.Time)
+			if !all && (next.IsZero() || next.After(now)) {
+				if !nextScanAt.Valid || !nextScanAt.Time.Equal(next) {
+					plans = append(plans, plan{id: t.id, next: next})
+				}
+				continue
+			}
+		}
+
+		parsed, err := scanner.ParseTarget(t.target)
+		if err != nil {
+			log.Printf("Failed to parse target %s: %v", t.target, err)
+			continue
+		}
+		t.parsed = parsed
+
+		t.ports, t.udpPorts = scanner.CommonPorts, scanner.CommonUDPPorts
+		if portSpec != "" {
+			ports, err := scanner.ParsePortSpec(portSpec)
+			if err != nil {
+				log.Printf("Invalid port profile for target %s, using common ports: %v", t.target, err)
+			} else {
+				t.ports = ports
+			}
+		}
+		if udpPortSpec != "" {
+			udpPorts, err := scanner.ParsePortSpec(udpPortSpec)
+			if err != nil {
+				log.Printf("Invalid UDP port profile for target %s, using common UDP ports: %v", t.target, err)
+			} else {
+				t.udpPorts = udpPorts
+			}
+		}
+
+		targets = append(targets, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range plans {
+		s.setNextScan(p.id, p.next)
+	}
+
+	return targets, nil
+}
+
+// scheduleFor parses a target's schedule, falling back to the default
+// interval when it has none or it can't be parsed
+func (s *Scheduler) scheduleFor(target, spec string) Schedule {
+	if spec == "" {
+		return intervalSchedule{interval: s.interval}
+	}
+
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		log.Printf("Invalid schedule for target %s, using default interval: %v", target, err)
+		return intervalSchedule{interval: s.interval}
+	}
+	return schedule
+}
+
+// markTargetsScheduled records that a run of targets started at startedAt
+// and plans each one's next run
+func (s *Scheduler) markTargetsScheduled(targets []scanTarget, startedAt time.Time) {
+	// Postgres keeps microseconds; match it so planned runs compare equal
+	startedAt = startedAt.UTC().Truncate(time.Microsecond)
+
+	for _, t := range targets {
+		next := t.schedule.Next(startedAt)
+
+		_, err := s.db.Exec(`
+			UPDATE scan_targets
+			SET last_scan_at = $1, next_scan_at = $2
+			WHERE id = $3
+		`, startedAt, nullTime(next), t.id)
+		if err != nil {
+			log.Printf("Failed to record scan time of target %s: %v", t.target, err)
+		}
+	}
+}
+
+func (s *Scheduler) setNextScan(targetID int, next time.Time) {
+	_, err := s.db.Exec(`
+		UPDATE scan_targets SET next_scan_at = $1 WHERE id = $2
+	`, nullTime(next), targetID)
+	if err != nil {
+		log.Printf("Failed to plan next scan of target %d: %v", targetID, err)
+	}
+}
+
+// nullTime stores the zero time, a schedule with no further runs, as NULL
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
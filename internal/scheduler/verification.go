@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"ip-scanner/internal/scanner"
+)
+
+const (
+	// verificationPollInterval is how often the queue is checked for
+	// verifications that are due
+	verificationPollInterval = 10 * time.Second
+
+	// verificationBatch bounds the verifications taken from the queue per poll
+	verificationBatch = 100
+
+	// verificationWorkers is how many verifications run at once
+	verificationWorkers = 20
+)
+
+// portVerification is a queued re-check of a port that was seen closing
+type portVerification struct {
+	id        int
+	sessionID int // the session that saw the port close
+	targetID  int
+	ip        string
+	port      int
+	protocol  string
+	engine    string
+	attempts  int
+}
+
+// schedulePortVerification queues a re-check of a port that was seen closing,
+// using the same engine. The queue lives in port_verifications so pending
+// checks survive a restart; a port already waiting for verification isn't
+// queued again.
+func (s *Scheduler) schedulePortVerification(sessionID, targetID int, ip string, port int, protocol, engineName string) {
+	result, err := s.db.Exec(`
+		INSERT INTO port_verifications (session_id, target_id, ip_address, port, protocol, engine, due_at)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second')
+		ON CONFLICT (ip_address, port, protocol) DO NOTHING
+	`, sessionID, targetID, ip, port, protocol, engineName, int(s.verifyDelay.Seconds()))
+	if err != nil {
+		log.Printf("Failed to queue verification for %s:%d/%s: %v", ip, port, protocol, err)
+		return
+	}
+
+	if queued, _ := result.RowsAffected(); queued > 0 {
+		log.Printf("Scheduling verification for %s:%d/%s in %v", ip, port, protocol, s.verifyDelay)
+	}
+}
+
+// runVerifications works through the verification queue until the scheduler
+// stops. Verifications that fell due while the API was down are picked up on
+// the first poll.
+func (s *Scheduler) runVerifications() {
+	defer s.wg.Done()
+
+	s.processVerifications()
+
+	ticker := time.NewTicker(verificationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.processVerifications()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// processVerifications runs the verifications that are due
+func (s *Scheduler) processVerifications() {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(session_id, 0), target_id, host(ip_address), port, protocol, engine, attempts
+		FROM port_verifications
+		WHERE due_at <= NOW()
+		ORDER BY due_at
+		LIMIT $1
+	`, verificationBatch)
+	if err != nil {
+		log.Printf("Failed to fetch pending verifications: %v", err)
+		return
+	}
+
+	pending := []portVerification{}
+	for rows.Next() {
+		var v portVerification
+		if err := rows.Scan(&v.id, &v.sessionID, &v.targetID, &v.ip, &v.port, &v.protocol, &v.engine, &v.attempts); err != nil {
+			log.Printf("Failed to scan verification row: %v", err)
+			continue
+		}
+		pending = append(pending, v)
+	}
+	rows.Close()
+
+	sem := make(chan struct{}, verificationWorkers)
+	var wg sync.WaitGroup
+	for _, v := range pending {
+		if s.ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(v portVerification) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.verifyPort(v)
+		}(v)
+	}
+	wg.Wait()
+}
+
+// verifyPort re-checks a queued port. The port was already seen closing, so
+// only seeing it open again cancels the closure: that is dropped without
+// notifying. A closed answer confirms the closure straight away. No answer
+// and a failed re-scan are treated alike: both are retried and, once the
+// configured retries are spent, the closure is confirmed and notified.
+func (s *Scheduler) verifyPort(v portVerification) {
+	log.Printf("Verifying port closure for %s:%d/%s", v.ip, v.port, v.protocol)
+
+	engine, err := scanner.Lookup(v.engine)
+	if err != nil {
+		// Retrying can't help, and the port was never seen open again
+		log.Printf("Failed to verify %s:%d/%s: %v", v.ip, v.port, v.protocol, err)
+		s.confirmClosure(v)
+		return
+	}
+
+	// Re-scan just this specific port
+	results, err := engine.ScanIP(s.ctx, v.ip, []int{v.port}, s.scanOptions)
+	if s.ctx.Err() != nil {
+		// Scheduler stopped; the verification stays queued for the next start
+		return
+	}
+	if err != nil || len(results) == 0 {
+		log.Printf("Failed to verify %s:%d/%s: %v", v.ip, v.port, v.protocol, err)
+		s.retryVerification(v)
+		return
+	}
+	result := results[0]
+
+	switch {
+	case result.Status == scanner.StatusOpen:
+		// Port reopened, log but don't notify
+		log.Printf("Verification failed: %s:%d/%s reopened, not recording closure", v.ip, v.port, v.protocol)
+	case result.Status != scanner.StatusClosed && v.attempts < s.verifyRetries:
+		// No answer either way; give the port another chance before notifying
+		log.Printf("Verification of %s:%d/%s inconclusive (%s), retrying", v.ip, v.port, v.protocol, result.Status)
+		s.retryVerification(v)
+		return
+	default:
+		// Port is still closed after verification, create notification
+		log.Printf("Verification confirmed: %s:%d/%s is still %s", v.ip, v.port, v.protocol, result.Status)
+		s.confirmClosure(v)
+		return
+	}
+
+	s.finishVerification(v)
+}
+
+// retryVerification puts a verification back on the queue. Once its retries
+// are spent the closure is confirmed, as the port was never seen open again.
+func (s *Scheduler) retryVerification(v portVerification) {
+	if v.attempts >= s.verifyRetries {
+		log.Printf("No answer verifying %s:%d/%s after %d attempts, confirming closure", v.ip, v.port, v.protocol, v.attempts+1)
+		s.confirmClosure(v)
+		return
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE port_verifications
+		SET attempts = attempts + 1, due_at = NOW() + $1 * INTERVAL '1 second'
+		WHERE id = $2
+	`, int(s.verifyDelay.Seconds()), v.id)
+	if err != nil {
+		log.Printf("Failed to requeue verification for %s:%d/%s: %v", v.ip, v.port, v.protocol, err)
+	}
+}
+
+// confirmClosure notifies that a verified port is closed and removes its
+// verification
+func (s *Scheduler) confirmClosure(v portVerification) {
+	s.createNotification(v.sessionID, v.targetID, v.ip, v.port, v.protocol, "port_closed")
+	s.finishVerification(v)
+}
+
+func (s *Scheduler) finishVerification(v portVerification) {
+	if _, err := s.db.Exec(`DELETE FROM port_verifications WHERE id = $1`, v.id); err != nil {
+		log.Printf("Failed to remove verification for %s:%d/%s: %v", v.ip, v.port, v.protocol, err)
+	}
+}
@@ -0,0 +1,11 @@
+-- Migration: Add UDP scanning support
+-- Targets can opt into UDP probes and results record which protocol was scanned
+
+ALTER TABLE scan_targets
+ADD COLUMN IF NOT EXISTS scan_udp BOOLEAN DEFAULT false;
+
+ALTER TABLE scan_results
+ADD COLUMN IF NOT EXISTS protocol VARCHAR(10) NOT NULL DEFAULT 'tcp'; -- 'tcp', 'udp'
+-- UDP results may also carry status 'open|filtered' when a probe gets no reply
+
+CREATE INDEX IF NOT EXISTS idx_scan_results_ip_port_protocol ON scan_results(ip_address, port, protocol);
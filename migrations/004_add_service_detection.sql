@@ -0,0 +1,7 @@
+-- Migration: Add service fingerprinting columns to scan_results
+-- Populated for open ports from banners and light protocol probes
+
+ALTER TABLE scan_results
+ADD COLUMN IF NOT EXISTS service_name VARCHAR(50), -- 'ssh', 'http', 'https', 'mysql', etc.
+ADD COLUMN IF NOT EXISTS service_product VARCHAR(255),
+ADD COLUMN IF NOT EXISTS service_version VARCHAR(100);
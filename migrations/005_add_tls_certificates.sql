@@ -0,0 +1,27 @@
+-- Migration: Add tls_certificates table
+-- Stores the certificate currently presented on each TLS-speaking IP/port
+
+CREATE TABLE IF NOT EXISTS tls_certificates (
+    id SERIAL PRIMARY KEY,
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    ip_address INET NOT NULL,
+    port INTEGER NOT NULL,
+    subject TEXT NOT NULL,
+    issuer TEXT NOT NULL,
+    sans TEXT[],
+    not_before TIMESTAMP NOT NULL,
+    not_after TIMESTAMP NOT NULL,
+    key_type VARCHAR(20),
+    key_size INTEGER,
+    self_signed BOOLEAN DEFAULT false,
+    tls_version VARCHAR(20),
+    cipher_suite VARCHAR(100),
+    fingerprint_sha256 VARCHAR(64) NOT NULL,
+    chain JSONB,
+    expiry_notified VARCHAR(20), -- 'expiring', 'expired' once the matching notification was raised
+    first_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT tls_certificates_ip_port_unique UNIQUE (ip_address, port)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tls_certificates_not_after ON tls_certificates(not_after);
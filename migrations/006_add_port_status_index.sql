@@ -0,0 +1,8 @@
+-- Migration: Index port status for the three-state port model
+-- scan_results.status now distinguishes why a port is not open:
+--   'closed'      - host refused the connection
+--   'filtered'    - connection attempt timed out (dropped by a firewall)
+--   'unreachable' - host or network unreachable
+-- Only transitions into or out of 'open' are treated as port events.
+
+CREATE INDEX IF NOT EXISTS idx_scan_results_status ON scan_results(status);
@@ -0,0 +1,31 @@
+-- Migration: Add port_profiles table
+-- Named port lists that can be assigned per target instead of the built-in
+-- common ports list
+
+CREATE TABLE IF NOT EXISTS port_profiles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    ports TEXT NOT NULL, -- e.g. '1-1024,3306,5432'
+    udp_ports TEXT, -- used when the target has scan_udp enabled; defaults to the common UDP ports
+    builtin BOOLEAN DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Targets without a profile keep scanning the common ports
+ALTER TABLE scan_targets
+ADD COLUMN IF NOT EXISTS port_profile_id INTEGER REFERENCES port_profiles(id) ON DELETE SET NULL;
+
+INSERT INTO port_profiles (name, description, ports, builtin) VALUES
+    ('common', 'Common service ports (default)',
+     '21-23,25,53,80,143,443,445,3306,3389,5900,8080,8443', true),
+    ('top-100', 'Top 100 most frequently open TCP ports',
+     '7,9,13,21-23,25-26,37,53,79-81,88,106,110-111,113,119,135,139,143-144,179,199,389,427,443-445,465,513-515,543-544,548,554,587,631,646,873,990,993,995,1025-1029,1110,1433,1720,1723,1755,1900,2000-2001,2049,2121,2717,3000,3128,3306,3389,3986,4899,5000,5009,5051,5060,5101,5190,5357,5432,5631,5666,5800,5900,6000-6001,6646,7070,8000,8008-8009,8080-8081,8443,8888,9100,9999-10000,32768,49152-49157', true),
+    ('web', 'HTTP and HTTPS services',
+     '80-81,443,591,3000,5000,8000,8008,8080-8081,8443,8888,9000,9443', true),
+    ('databases', 'Database and cache servers',
+     '1433,1521,3306,5432,5984,6379,7000-7001,8086,9042,9200,9300,11211,27017-27019', true),
+    ('full', 'All TCP ports (1-65535)',
+     '1-65535', true)
+ON CONFLICT (name) DO NOTHING;
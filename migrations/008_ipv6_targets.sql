@@ -0,0 +1,6 @@
+-- Migration: IPv6 target support
+-- Targets may now be explicit address lists (e.g. IPv6 hitlists), which can
+-- exceed the original 255 character limit
+
+ALTER TABLE scan_targets
+ALTER COLUMN target TYPE TEXT; -- IP address, CIDR subnet, or comma/whitespace separated list of either
@@ -0,0 +1,16 @@
+-- Migration: Add dns_resolutions table
+-- Host name targets are re-resolved at every scan; this records what each
+-- name resolved to in each scan session
+
+CREATE TABLE IF NOT EXISTS dns_resolutions (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER REFERENCES scan_sessions(id) ON DELETE CASCADE,
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    hostname VARCHAR(253) NOT NULL,
+    addresses INET[] NOT NULL DEFAULT '{}', -- sorted A and AAAA results
+    error TEXT, -- set when the lookup failed
+    resolved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dns_resolutions_target_hostname ON dns_resolutions(target_id, hostname, resolved_at DESC);
+CREATE INDEX IF NOT EXISTS idx_dns_resolutions_session_id ON dns_resolutions(session_id);
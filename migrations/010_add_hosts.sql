@@ -0,0 +1,11 @@
+-- Migration: Add hosts table
+-- Caches host names for scanned IPs, from reverse DNS (PTR) and from the
+-- SANs of certificates presented on TLS ports
+
+CREATE TABLE IF NOT EXISTS hosts (
+    ip_address INET PRIMARY KEY,
+    ptr_names TEXT[] NOT NULL DEFAULT '{}',
+    cert_names TEXT[] NOT NULL DEFAULT '{}',
+    ptr_resolved_at TIMESTAMP, -- last reverse lookup, used to expire the PTR cache
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
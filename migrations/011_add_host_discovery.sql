@@ -0,0 +1,23 @@
+-- Migration: Add host discovery
+-- Targets can probe each address before port scanning it and skip hosts that
+-- don't answer. The outcome is recorded per session.
+
+ALTER TABLE scan_targets
+ADD COLUMN IF NOT EXISTS discovery_method VARCHAR(10) NOT NULL DEFAULT 'none'; -- none, icmp, tcp, arp, auto
+
+CREATE TABLE IF NOT EXISTS host_discovery (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER REFERENCES scan_sessions(id) ON DELETE CASCADE,
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    ip_address INET NOT NULL,
+    is_up BOOLEAN NOT NULL,
+    method VARCHAR(10) NOT NULL, -- the method that answered, or the last one tried
+    discovered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_host_discovery_session ON host_discovery(session_id, is_up);
+CREATE INDEX IF NOT EXISTS idx_host_discovery_ip ON host_discovery(ip_address, discovered_at DESC);
+
+ALTER TABLE scan_sessions
+ADD COLUMN IF NOT EXISTS hosts_up INTEGER DEFAULT 0,
+ADD COLUMN IF NOT EXISTS hosts_down INTEGER DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Migration: Record the scan engine on each result
+-- connect, syn or udp; older rows were all produced by connect or udp scans
+
+ALTER TABLE scan_results
+ADD COLUMN IF NOT EXISTS engine VARCHAR(10);
@@ -0,0 +1,5 @@
+-- Migration: Per-target scan engine
+-- NULL uses the engine configured with TCP_SCAN_ENGINE
+
+ALTER TABLE scan_targets
+ADD COLUMN IF NOT EXISTS scan_engine VARCHAR(10);
@@ -0,0 +1,6 @@
+-- Migration: Cancelled scan sessions
+-- scan_sessions.status may now also be 'cancelled' when a scan is stopped by
+-- shutdown, its time budget or a user; cancel_reason says which
+
+ALTER TABLE scan_sessions
+ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
@@ -0,0 +1,6 @@
+-- Migration: Record who cancelled a scan session
+-- Set when a scan is cancelled through the API; sessions stopped by shutdown
+-- or their time budget leave it NULL
+
+ALTER TABLE scan_sessions
+ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(255);
@@ -0,0 +1,21 @@
+-- Migration: Add adhoc_scans table
+-- On-demand scans of an IP, range or host name that need not be a saved
+-- target. Only jobs too large to answer synchronously are stored. Their
+-- results live here rather than in scan_results so they don't feed change
+-- detection or notifications.
+
+CREATE TABLE IF NOT EXISTS adhoc_scans (
+    id SERIAL PRIMARY KEY,
+    target TEXT NOT NULL,
+    ports INTEGER[] NOT NULL,
+    udp_ports INTEGER[] NOT NULL DEFAULT '{}',
+    engine VARCHAR(10) NOT NULL,
+    status VARCHAR(20) DEFAULT 'running', -- 'running', 'completed', 'failed', 'cancelled'
+    requested_by VARCHAR(255),
+    results JSONB,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_adhoc_scans_created ON adhoc_scans(created_at DESC);
@@ -0,0 +1,15 @@
+-- Migration: Add per-target scan schedules
+-- schedule is a cron expression or an interval such as "@every 5m"; NULL uses
+-- the scheduler's default interval. last_scan_at is when the target's last
+-- scheduled run started and next_scan_at when the next one is planned.
+-- Each due target is scanned in its own session, so several sessions can run
+-- at once; scan_sessions.target_id is the target a session scanned. Sessions
+-- from before this change covered every due target and leave it empty.
+
+ALTER TABLE scan_targets ADD COLUMN IF NOT EXISTS schedule VARCHAR(100);
+ALTER TABLE scan_targets ADD COLUMN IF NOT EXISTS last_scan_at TIMESTAMP;
+ALTER TABLE scan_targets ADD COLUMN IF NOT EXISTS next_scan_at TIMESTAMP;
+
+ALTER TABLE scan_sessions ADD COLUMN IF NOT EXISTS target_id INTEGER REFERENCES scan_targets(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_scan_sessions_target_id ON scan_sessions(target_id);
@@ -0,0 +1,28 @@
+-- Migration: Add blackout_windows table
+-- Periods during which scheduled scans are held back, either for every target
+-- (target_id NULL) or for one. A recurring window opens whenever its cron
+-- recurrence matches, in its time zone, and lasts duration_minutes; a one-off
+-- window runs from starts_at to ends_at (UTC). action 'skip' drops the run,
+-- 'defer' holds it until the window closes.
+
+CREATE TABLE IF NOT EXISTS blackout_windows (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    recurrence VARCHAR(100), -- e.g. '0 22 * * 2' for Tuesdays at 22:00
+    duration_minutes INTEGER,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    action VARCHAR(10) NOT NULL DEFAULT 'defer', -- 'skip' or 'defer'
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((recurrence IS NOT NULL AND duration_minutes > 0) OR (starts_at IS NOT NULL AND ends_at > starts_at))
+);
+
+CREATE INDEX IF NOT EXISTS idx_blackout_windows_target ON blackout_windows(target_id);
+
+-- scan_sessions.status may now also be 'skipped' when every due target was
+-- held back by a blackout window; skip_reason lists the targets held back
+ALTER TABLE scan_sessions
+ADD COLUMN IF NOT EXISTS skip_reason TEXT;
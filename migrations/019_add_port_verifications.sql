@@ -0,0 +1,19 @@
+-- Migration: Add port_verifications table
+-- Ports seen closing are re-checked after a delay before a port_closed
+-- notification is raised. Pending checks are queued here so they survive a
+-- restart; there is at most one per IP, port and protocol.
+
+CREATE TABLE IF NOT EXISTS port_verifications (
+    id SERIAL PRIMARY KEY,
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    ip_address INET NOT NULL,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL DEFAULT 'tcp',
+    engine VARCHAR(10) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0, -- checks that got no answer so far
+    due_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (ip_address, port, protocol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_port_verifications_due ON port_verifications(due_at);
@@ -0,0 +1,19 @@
+-- Migration: Add port_flaps table
+-- Tracks recent open/closed transitions of each IP/port. A port that changes
+-- state too often within the window is flapping: it raises one
+-- port_flapping notification and its per-transition alerts are suppressed
+-- until it has been stable for a whole window.
+
+CREATE TABLE IF NOT EXISTS port_flaps (
+    ip_address INET NOT NULL,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL DEFAULT 'tcp',
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    transitions TIMESTAMP[] NOT NULL DEFAULT '{}', -- within the flap window
+    last_transition_at TIMESTAMP NOT NULL,
+    flapping BOOLEAN NOT NULL DEFAULT false,
+    flapping_since TIMESTAMP,
+    PRIMARY KEY (ip_address, port, protocol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_port_flaps_flapping ON port_flaps(flapping) WHERE flapping;
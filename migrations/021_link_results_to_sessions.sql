@@ -0,0 +1,34 @@
+-- Migration: Link scan results and notifications to their scan session
+-- scan_results.change marks a port that opened ('new') or closed ('closed')
+-- since the previous result, and sessions keep a summary of their findings.
+
+ALTER TABLE scan_results
+ADD COLUMN IF NOT EXISTS session_id INTEGER REFERENCES scan_sessions(id) ON DELETE SET NULL;
+
+ALTER TABLE scan_results
+ADD COLUMN IF NOT EXISTS change VARCHAR(10); -- 'new', 'closed'
+
+CREATE INDEX IF NOT EXISTS idx_scan_results_session ON scan_results(session_id);
+
+ALTER TABLE notifications
+ADD COLUMN IF NOT EXISTS session_id INTEGER REFERENCES scan_sessions(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_session ON notifications(session_id);
+
+-- Closure notifications belong to the session that saw the port close
+ALTER TABLE port_verifications
+ADD COLUMN IF NOT EXISTS session_id INTEGER REFERENCES scan_sessions(id) ON DELETE SET NULL;
+
+ALTER TABLE scan_sessions
+ADD COLUMN IF NOT EXISTS open_ports INTEGER DEFAULT 0,
+ADD COLUMN IF NOT EXISTS new_ports INTEGER DEFAULT 0,
+ADD COLUMN IF NOT EXISTS closed_ports INTEGER DEFAULT 0;
+
+-- Sessions never overlap, so existing results belong to the session running
+-- when they were scanned
+UPDATE scan_results sr
+SET session_id = ss.id
+FROM scan_sessions ss
+WHERE sr.session_id IS NULL
+  AND sr.scanned_at >= ss.started_at
+  AND sr.scanned_at <= COALESCE(ss.completed_at, NOW());
@@ -0,0 +1,114 @@
+-- Migration: Store port state changes instead of every probe result
+-- port_events is an append-only history with one row per state change of an
+-- IP/port. While the port stays in that state, its latest event is only
+-- stamped with when, and in which session, it was last seen. port_state keeps
+-- the current state of each IP/port, updated in place on every scan.
+-- Port state is keyed by IP/port/protocol alone, so targets that overlap
+-- share it: a port is compared with whichever target scanned it last, and
+-- target_id is that target.
+-- scan_results is no longer written; existing rows are folded into the new
+-- tables below and left in place.
+
+CREATE TABLE IF NOT EXISTS port_events (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER REFERENCES scan_sessions(id) ON DELETE SET NULL, -- the session that saw the change
+    last_session_id INTEGER REFERENCES scan_sessions(id) ON DELETE SET NULL, -- the last session that saw this state
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    ip_address INET NOT NULL,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL DEFAULT 'tcp',
+    previous_status VARCHAR(20), -- NULL on first sight
+    status VARCHAR(20) NOT NULL,
+    change VARCHAR(10), -- 'new', 'closed'
+    response_time_ms INTEGER,
+    service_name VARCHAR(50),
+    service_product VARCHAR(255),
+    service_version VARCHAR(100),
+    engine VARCHAR(10),
+    occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_port_events_key ON port_events(ip_address, port, protocol, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_port_events_occurred_at ON port_events(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_port_events_session ON port_events(session_id);
+CREATE INDEX IF NOT EXISTS idx_port_events_last_session ON port_events(last_session_id);
+
+CREATE TABLE IF NOT EXISTS port_state (
+    ip_address INET NOT NULL,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL DEFAULT 'tcp',
+    target_id INTEGER REFERENCES scan_targets(id) ON DELETE CASCADE,
+    event_id INTEGER REFERENCES port_events(id) ON DELETE SET NULL, -- the event that began the current state
+    session_id INTEGER REFERENCES scan_sessions(id) ON DELETE SET NULL, -- the last session that saw the port
+    status VARCHAR(20) NOT NULL,
+    response_time_ms INTEGER,
+    service_name VARCHAR(50),
+    service_product VARCHAR(255),
+    service_version VARCHAR(100),
+    engine VARCHAR(10),
+    first_open_at TIMESTAMP,
+    changed_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (ip_address, port, protocol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_port_state_status ON port_state(status);
+CREATE INDEX IF NOT EXISTS idx_port_state_target ON port_state(target_id);
+
+-- Fold existing results into events: each run of identical statuses of an
+-- IP/port becomes one event, last seen at the run's final result
+WITH ordered AS (
+    SELECT sr.*,
+           LAG(sr.status) OVER (PARTITION BY ip_address, port, protocol ORDER BY scanned_at, id) AS previous_status
+    FROM scan_results sr
+),
+runs AS (
+    SELECT o.*,
+           COUNT(*) FILTER (WHERE previous_status IS DISTINCT FROM status)
+               OVER (PARTITION BY ip_address, port, protocol ORDER BY scanned_at, id) AS run
+    FROM ordered o
+),
+spans AS (
+    SELECT r.*,
+           MAX(session_id) OVER (PARTITION BY ip_address, port, protocol, run) AS run_last_session_id,
+           MAX(scanned_at) OVER (PARTITION BY ip_address, port, protocol, run) AS run_last_seen_at
+    FROM runs r
+)
+INSERT INTO port_events (session_id, last_session_id, target_id, ip_address, port, protocol,
+    previous_status, status, change, response_time_ms, service_name, service_product, service_version,
+    engine, occurred_at, last_seen_at)
+SELECT DISTINCT ON (ip_address, port, protocol, run)
+       session_id, run_last_session_id, target_id, ip_address, port, protocol,
+       previous_status, status,
+       -- As in portChange: a UDP probe without a reply is neither open nor closed
+       CASE
+           WHEN status = 'open|filtered' OR previous_status = 'open|filtered' THEN NULL
+           WHEN status = 'open' THEN 'new'
+           WHEN previous_status = 'open' THEN 'closed'
+       END,
+       response_time_ms, service_name, service_product, service_version,
+       engine, scanned_at, run_last_seen_at
+FROM spans
+WHERE NOT EXISTS (SELECT 1 FROM port_events)
+ORDER BY ip_address, port, protocol, run, scanned_at, id;
+
+INSERT INTO port_state (ip_address, port, protocol, target_id, event_id, session_id, status,
+    response_time_ms, service_name, service_product, service_version, engine,
+    first_open_at, changed_at, last_seen_at)
+SELECT DISTINCT ON (sr.ip_address, sr.port, sr.protocol)
+       sr.ip_address, sr.port, sr.protocol, sr.target_id, pe.id, sr.session_id, sr.status,
+       sr.response_time_ms, sr.service_name, sr.service_product, sr.service_version, sr.engine,
+       (SELECT MIN(occurred_at) FROM port_events fo
+        WHERE fo.ip_address = sr.ip_address AND fo.port = sr.port AND fo.protocol = sr.protocol
+          AND fo.status = 'open'),
+       pe.occurred_at, sr.scanned_at
+FROM scan_results sr
+JOIN LATERAL (
+    SELECT id, occurred_at FROM port_events le
+    WHERE le.ip_address = sr.ip_address AND le.port = sr.port AND le.protocol = sr.protocol
+    ORDER BY occurred_at DESC, id DESC
+    LIMIT 1
+) pe ON true
+ORDER BY sr.ip_address, sr.port, sr.protocol, sr.scanned_at DESC, sr.id DESC
+ON CONFLICT (ip_address, port, protocol) DO NOTHING;
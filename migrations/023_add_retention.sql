@@ -0,0 +1,32 @@
+-- Migration: Add data retention
+-- A background job prunes raw scan data, old notifications and finished
+-- ad-hoc scans. Scan sessions are compacted into one rollup row per day
+-- before they are pruned, and rollups are kept for longer. Each run of the
+-- job is recorded with how many rows it pruned from each table.
+
+CREATE TABLE IF NOT EXISTS daily_rollups (
+    day DATE PRIMARY KEY,
+    sessions INTEGER NOT NULL DEFAULT 0,
+    completed_sessions INTEGER NOT NULL DEFAULT 0,
+    ports_scanned BIGINT NOT NULL DEFAULT 0,
+    max_open_ports INTEGER NOT NULL DEFAULT 0,
+    new_ports INTEGER NOT NULL DEFAULT 0,
+    closed_ports INTEGER NOT NULL DEFAULT 0,
+    max_hosts_up INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id SERIAL PRIMARY KEY,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    status VARCHAR(20) DEFAULT 'running', -- 'running', 'completed', 'failed'
+    rows_pruned JSONB NOT NULL DEFAULT '{}', -- table name -> rows deleted
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started_at ON retention_runs(started_at DESC);
+
+-- Support pruning rows older than the raw retention period
+CREATE INDEX IF NOT EXISTS idx_dns_resolutions_resolved_at ON dns_resolutions(resolved_at);
+CREATE INDEX IF NOT EXISTS idx_host_discovery_discovered_at ON host_discovery(discovered_at);
@@ -0,0 +1,6 @@
+-- Migration: Keep the status of a port when it started flapping
+-- Alerts are suppressed while a port flaps. Once it settles, its current
+-- status is compared with this one, so a port that ended up opened or closed
+-- is still alerted on once.
+
+ALTER TABLE port_flaps ADD COLUMN IF NOT EXISTS status_before VARCHAR(20);
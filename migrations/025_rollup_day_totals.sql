@@ -0,0 +1,17 @@
+-- Migration: Store day totals in daily rollups
+-- Each target is scanned in its own session, so the largest session of a day
+-- no longer covers every target. open_ports and hosts_up add up the last
+-- completed session of each target that day instead. Days rolled up before
+-- this change came from sessions covering every target and keep their values.
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'daily_rollups' AND column_name = 'max_open_ports') THEN
+        ALTER TABLE daily_rollups RENAME COLUMN max_open_ports TO open_ports;
+    END IF;
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'daily_rollups' AND column_name = 'max_hosts_up') THEN
+        ALTER TABLE daily_rollups RENAME COLUMN max_hosts_up TO hosts_up;
+    END IF;
+END $$;
@@ -0,0 +1,7 @@
+-- Migration: Clear port changes backfilled from UDP probes without a reply
+-- The backfill in 022 marked moves between open and open|filtered as ports
+-- opening or closing. A UDP probe without a reply is neither, and scans
+-- never record such a change, so it is cleared here.
+
+UPDATE port_events SET change = NULL
+WHERE change IS NOT NULL AND (status = 'open|filtered' OR previous_status = 'open|filtered');